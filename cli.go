@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	proto "github.com/hashicorp/sentinel-sdk/proto/go"
+)
+
+const usage = `Usage: sentinel-plugin-demo [command] [args]
+
+With no command the plugin server is started, as Sentinel expects.
+
+Commands:
+  call <function> [args...]   Call a function and print the result as JSON
+  get <property>              Read a property and print it as JSON
+  list                        List the names of all functions and properties
+  describe [-json] [name]     Describe all functions and properties, or one
+  docs [-format f] [-o file]  Generate the reference docs as markdown or json
+  repl                        Start an interactive session to try out calls
+  --version                   Print the version and build information
+
+Arguments to call are passed as strings when the function expects a
+string, and parsed as JSON otherwise. Set SENTINEL_PLUGIN_DEMO_LOG_LEVEL
+(e.g. debug) to see the plugin's logs on stderr.
+`
+
+// runCLI runs a command against the plugin in-process and returns the
+// exit code: 0 on success, 1 if the plugin returned an error and 2 for
+// usage errors.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	// Plugin logs are for the Sentinel host; by hand they are opt-in
+	logLevel := os.Getenv("SENTINEL_PLUGIN_DEMO_LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "off"
+	}
+
+	h, err := newHost(map[string]interface{}{"log_level": logLevel, "log_format": "text"})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "call":
+		return cliCall(h, args[1:], stdout, stderr)
+	case "get":
+		return cliGet(h, args[1:], stdout, stderr)
+	case "list":
+		return cliList(h, args[1:], stdout, stderr)
+	case "describe":
+		return cliDescribe(h, args[1:], stdout, stderr)
+	case "docs":
+		return cliDocs(h, args[1:], stdout, stderr)
+	case "repl":
+		return runREPL(h, stdin, stdout)
+	case "--version", "-version":
+		return cliVersion(h, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+func cliCall(h *host, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, "usage: call <function> [args...]\n")
+		return 2
+	}
+
+	entry, err := h.lookup(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	if entry == nil || entry.Kind != "function" {
+		fmt.Fprintf(stderr, "unknown function %q, see the list command\n", args[0])
+		return 2
+	}
+	if len(args)-1 != len(entry.Args) {
+		fmt.Fprintf(stderr, "usage: call %s\n", entry.Signature)
+		return 2
+	}
+
+	callArgs := make([]interface{}, len(entry.Args))
+	for i, a := range entry.Args {
+		v, err := parseArg(args[i+1], a.Type)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", a.Name, err)
+			return 2
+		}
+		callArgs[i] = v
+	}
+
+	v, err := h.call(entry.Name, callArgs)
+	return printValue(v, err, stdout, stderr)
+}
+
+func cliGet(h *host, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprint(stderr, "usage: get <property>\n")
+		return 2
+	}
+
+	entry, err := h.lookup(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	if entry == nil || entry.Kind != "property" {
+		fmt.Fprintf(stderr, "unknown property %q, see the list command\n", args[0])
+		return 2
+	}
+
+	v, err := h.property(entry.Name)
+	return printValue(v, err, stdout, stderr)
+}
+
+func cliList(h *host, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprint(stderr, "usage: list\n")
+		return 2
+	}
+
+	entries, err := h.catalog()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return printJSON(names, stdout, stderr)
+}
+
+func cliDescribe(h *host, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("describe", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the catalog as JSON")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		fmt.Fprint(stderr, "usage: describe [-json] [name]\n")
+		return 2
+	}
+
+	entries, err := h.catalog()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	if name := flags.Arg(0); name != "" {
+		var match []*catalogEntry
+		for _, e := range entries {
+			if e.Name == name {
+				match = append(match, e)
+			}
+		}
+		if len(match) == 0 {
+			fmt.Fprintf(stderr, "unknown function or property %q, see the list command\n", name)
+			return 2
+		}
+		entries = match
+	}
+
+	if *asJSON {
+		return printJSON(entries, stdout, stderr)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Kind, e.Signature, e.Description)
+	}
+	w.Flush()
+	return 0
+}
+
+func cliVersion(h *host, stdout, stderr io.Writer) int {
+	v, err := h.property("version")
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	info, _ := valueToJSON(v).(map[string]interface{})
+	fmt.Fprintf(stdout, "sentinel-plugin-demo %s\n", info["version"])
+	for _, k := range []string{"git_commit", "git_commit_date", "build_date", "go_version", "sdk_version"} {
+		if s, _ := info[k].(string); s != "" {
+			if k == "git_commit" && info["git_dirty"] == true {
+				s += " (dirty)"
+			}
+			fmt.Fprintf(stdout, "  %-16s %s\n", k, s)
+		}
+	}
+	return 0
+}
+
+// printValue prints a Sentinel value as JSON, or "undefined"
+func printValue(v *proto.Value, err error, stdout, stderr io.Writer) int {
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	if v.Type == proto.Value_UNDEFINED {
+		fmt.Fprintln(stdout, "undefined")
+		return 0
+	}
+	return printJSON(valueToJSON(v), stdout, stderr)
+}
+
+func printJSON(v interface{}, stdout, stderr io.Writer) int {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(out))
+	return 0
+}
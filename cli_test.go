@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"sentinel-plugin-demo/plugin"
+)
+
+func TestRunCLI(t *testing.T) {
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(args, strings.NewReader(""), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// Test that call prints the function result as JSON
+	t.Run("CallPrintsJSON", func(t *testing.T) {
+		os.Setenv("TEST_CLI_VAR", "cli value")
+		defer os.Unsetenv("TEST_CLI_VAR")
+
+		code, stdout, stderr := run("call", "getenv", "TEST_CLI_VAR")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+		}
+		if strings.TrimSpace(stdout) != `"cli value"` {
+			t.Errorf("Expected \"cli value\", got %s", stdout)
+		}
+	})
+
+	// Test that undefined results are printed as undefined
+	t.Run("CallPrintsUndefined", func(t *testing.T) {
+		code, stdout, _ := run("call", "getfile", "/absolutely/non/existent/file/path/12345.txt")
+		if code != 0 || strings.TrimSpace(stdout) != "undefined" {
+			t.Errorf("Expected undefined with exit code 0, got %d: %s", code, stdout)
+		}
+	})
+
+	// Test that plugin errors exit with 1
+	t.Run("CallReportsErrors", func(t *testing.T) {
+		code, _, stderr := run("call", "decode", "zz", "hex")
+		if code != 1 || !strings.Contains(stderr, "decode") {
+			t.Errorf("Expected decode error with exit code 1, got %d: %s", code, stderr)
+		}
+	})
+
+	// Test that wrong argument counts are usage errors
+	t.Run("CallChecksArgumentCount", func(t *testing.T) {
+		code, _, stderr := run("call", "getenv")
+		if code != 2 || !strings.Contains(stderr, "getenv(key string)") {
+			t.Errorf("Expected usage error with exit code 2, got %d: %s", code, stderr)
+		}
+	})
+
+	// Test that get prints a property
+	t.Run("GetPrintsProperty", func(t *testing.T) {
+		dir, _ := os.Getwd()
+		code, stdout, stderr := run("get", "pwd")
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+		}
+
+		var got string
+		if err := json.Unmarshal([]byte(stdout), &got); err != nil || got != dir {
+			t.Errorf("Expected %q, got %s", dir, stdout)
+		}
+	})
+
+	// Test that list includes functions and properties
+	t.Run("ListsNames", func(t *testing.T) {
+		code, stdout, _ := run("list")
+		var names []string
+		if err := json.Unmarshal([]byte(stdout), &names); err != nil || code != 0 {
+			t.Fatalf("list should print a JSON list, got %d: %s", code, stdout)
+		}
+		if !strings.Contains(strings.Join(names, ","), "getfile") {
+			t.Errorf("Expected getfile in %v", names)
+		}
+	})
+
+	// Test that describe -json prints the catalog
+	t.Run("DescribesAsJSON", func(t *testing.T) {
+		code, stdout, _ := run("describe", "-json", "archive_read")
+		var entries []*catalogEntry
+		if err := json.Unmarshal([]byte(stdout), &entries); err != nil || code != 0 {
+			t.Fatalf("describe -json should print a JSON list, got %d: %s", code, stdout)
+		}
+		if len(entries) != 1 || len(entries[0].Args) != 2 {
+			t.Errorf("Unexpected describe output %s", stdout)
+		}
+	})
+
+	// Test that --version prints the plugin version
+	t.Run("PrintsVersion", func(t *testing.T) {
+		code, stdout, _ := run("--version")
+		if code != 0 || !strings.HasPrefix(stdout, "sentinel-plugin-demo "+plugin.Version) {
+			t.Errorf("Unexpected version output %d: %s", code, stdout)
+		}
+	})
+
+	// Test that unknown commands are usage errors
+	t.Run("RejectsUnknownCommand", func(t *testing.T) {
+		if code, _, _ := run("frobnicate"); code != 2 {
+			t.Errorf("Expected exit code 2, got %d", code)
+		}
+	})
+}
+
+func TestParseArg(t *testing.T) {
+	cases := []struct {
+		raw  string
+		typ  string
+		want interface{}
+	}{
+		{"42", "string", "42"},
+		{"42", "int", int64(42)},
+		{"1.5", "float", 1.5},
+		{"true", "bool", true},
+		{"HOME", "any", "HOME"},
+	}
+	for _, tc := range cases {
+		got, err := parseArg(tc.raw, tc.typ)
+		if err != nil || got != tc.want {
+			t.Errorf("parseArg(%q, %q) = %#v, %v; want %#v", tc.raw, tc.typ, got, err, tc.want)
+		}
+	}
+
+	if _, err := parseArg("not json", "list(string)"); err == nil {
+		t.Error("parseArg should reject invalid JSON for non-string types")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// renderMarkdown renders the catalog as a Markdown reference
+func renderMarkdown(entries []*catalogEntry) string {
+	var b strings.Builder
+	b.WriteString("# Plugin Reference\n\n")
+	b.WriteString("<!-- Generated by `sentinel-plugin-demo docs`, do not edit by hand. -->\n\n")
+	b.WriteString("Import the plugin in a policy with `import \"plugin-demo\" as pd`.\n")
+
+	for _, section := range []struct{ kind, title string }{
+		{"function", "Functions"},
+		{"property", "Properties"},
+	} {
+		fmt.Fprintf(&b, "\n## %s\n", section.title)
+		for _, e := range entries {
+			if e.Kind != section.kind {
+				continue
+			}
+
+			fmt.Fprintf(&b, "\n### %s\n\n", e.Name)
+			fmt.Fprintf(&b, "```text\n%s\n```\n\n", e.Signature)
+			fmt.Fprintf(&b, "%s\n", e.Description)
+
+			if len(e.Args) > 0 {
+				b.WriteString("\n| Argument | Type |\n| --- | --- |\n")
+				for _, a := range e.Args {
+					fmt.Fprintf(&b, "| `%s` | `%s` |\n", a.Name, a.Type)
+				}
+			}
+
+			fmt.Fprintf(&b, "\n**Returns:** `%s`\n", e.Shape)
+
+			if e.Example != "" {
+				fmt.Fprintf(&b, "\n**Example:**\n\n```sentinel\nimport \"plugin-demo\" as pd\n\n%s\n```\n", e.Example)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func cliDocs(h *host, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("docs", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "markdown", "output format, markdown or json")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		fmt.Fprint(stderr, "usage: docs [-format markdown|json] [-o file]\n")
+		return 2
+	}
+
+	entries, err := h.catalog()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	// Render before touching the output file, so a bad format or a
+	// failed render leaves an existing file as it was
+	var buf bytes.Buffer
+	switch *format {
+	case "markdown", "md":
+		buf.WriteString(renderMarkdown(entries))
+	case "json":
+		if code := printJSON(entries, &buf, stderr); code != 0 {
+			return code
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown format %q, expected markdown or json\n", *format)
+		return 2
+	}
+
+	if *output == "" {
+		stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	h, err := newHost(map[string]interface{}{"log_level": "off"})
+	if err != nil {
+		t.Fatalf("newHost should not return error: %v", err)
+	}
+	entries, err := h.catalog()
+	if err != nil {
+		t.Fatalf("catalog should not return error: %v", err)
+	}
+
+	// Test that the checked in reference matches the code
+	t.Run("ReferenceIsUpToDate", func(t *testing.T) {
+		want, err := os.ReadFile("docs/REFERENCE.md")
+		if err != nil {
+			t.Fatalf("Failed to read docs/REFERENCE.md: %v", err)
+		}
+		if renderMarkdown(entries) != string(want) {
+			t.Error("docs/REFERENCE.md is out of date, run `task docs`")
+		}
+	})
+
+	// Test that every entry is rendered with its example and shape
+	t.Run("RendersEveryEntry", func(t *testing.T) {
+		md := renderMarkdown(entries)
+		for _, e := range entries {
+			if !strings.Contains(md, "### "+e.Name+"\n") {
+				t.Errorf("%s: missing heading", e.Name)
+			}
+			if e.Example == "" {
+				t.Errorf("%s: missing example", e.Name)
+			}
+			if !strings.Contains(md, "**Returns:** `"+e.Shape+"`") {
+				t.Errorf("%s: missing return shape", e.Name)
+			}
+		}
+	})
+
+	// Test that docs -format json prints the catalog
+	t.Run("PrintsJSON", func(t *testing.T) {
+		var stdout, stderr strings.Builder
+		if code := cliDocs(h, []string{"-format", "json"}, &stdout, &stderr); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), `"shape"`) {
+			t.Errorf("Expected shape in JSON output, got %s", stdout.String())
+		}
+	})
+
+	// Test that -o writes the file, and that an unknown format leaves an
+	// existing file untouched
+	t.Run("WritesOutputFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "REFERENCE.md")
+		var stdout, stderr strings.Builder
+		if code := cliDocs(h, []string{"-o", path}, &stdout, &stderr); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+		}
+		if got, _ := os.ReadFile(path); string(got) != renderMarkdown(entries) || stdout.Len() != 0 {
+			t.Errorf("Expected the reference in %s and nothing on stdout, got %d bytes and %q", path, len(got), stdout.String())
+		}
+
+		stderr.Reset()
+		if code := cliDocs(h, []string{"-format", "html", "-o", path}, &stdout, &stderr); code != 2 {
+			t.Errorf("Expected exit code 2, got %d", code)
+		}
+		if got, _ := os.ReadFile(path); string(got) != renderMarkdown(entries) {
+			t.Errorf("Expected %s to be left as it was, got %d bytes", path, len(got))
+		}
+	})
+}
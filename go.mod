@@ -2,13 +2,21 @@ module sentinel-plugin-demo
 
 go 1.23.4
 
-require github.com/hashicorp/sentinel-sdk v0.5.2
+require (
+	github.com/ProtonMail/go-crypto v1.1.3
+	github.com/hashicorp/go-hclog v1.5.0
+	github.com/hashicorp/go-version v1.7.0
+	github.com/hashicorp/sentinel-sdk v0.5.2
+	github.com/jmespath/go-jmespath v0.4.0
+	github.com/klauspost/compress v1.17.11
+	golang.org/x/crypto v0.28.0
+)
 
 require (
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fatih/color v1.15.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
-	github.com/hashicorp/go-hclog v1.5.0 // indirect
 	github.com/hashicorp/go-plugin v1.5.2 // indirect
 	github.com/hashicorp/yamux v0.1.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -18,9 +26,9 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.1 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
-	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
-	golang.org/x/text v0.13.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20231016165738-49dd2c1f3d0b // indirect
 	google.golang.org/grpc v1.59.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sentinel-plugin-demo/plugin"
+
+	sdk "github.com/hashicorp/sentinel-sdk"
+	"github.com/hashicorp/sentinel-sdk/encoding"
+	proto "github.com/hashicorp/sentinel-sdk/proto/go"
+)
+
+// host invokes the plugin in-process through the same framework and
+// encoding layers the Sentinel runtime goes through over RPC, so what it
+// returns is exactly what a policy would see.
+type host struct {
+	plugin sdk.Plugin
+}
+
+// Return structs, mirroring the entries of the functions property
+type catalogEntry struct {
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Signature   string    `json:"signature"`
+	Args        []argSpec `json:"args"`
+	Returns     string    `json:"returns"`
+	Shape       string    `json:"shape"`
+	Description string    `json:"description"`
+	Example     string    `json:"example"`
+}
+
+type argSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func newHost(config map[string]interface{}) (*host, error) {
+	p := plugin.New()
+	if err := p.Configure(config); err != nil {
+		return nil, fmt.Errorf("error configuring plugin: %s", err)
+	}
+	return &host{plugin: p}, nil
+}
+
+// call invokes a function with arguments already converted to Go values
+func (h *host) call(name string, args []interface{}) (*proto.Value, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	return h.get(sdk.GetKey{Key: name, Args: args})
+}
+
+// property reads a property
+func (h *host) property(name string) (*proto.Value, error) {
+	return h.get(sdk.GetKey{Key: name})
+}
+
+func (h *host) get(key sdk.GetKey) (*proto.Value, error) {
+	resp, err := h.plugin.Get([]*sdk.GetReq{{Keys: []sdk.GetKey{key}, KeyId: 1}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(resp))
+	}
+	return encoding.GoToValue(resp[0].Value)
+}
+
+// catalog reads the functions property and decodes it
+func (h *host) catalog() ([]*catalogEntry, error) {
+	v, err := h.property("functions")
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(valueToJSON(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*catalogEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// lookup returns the catalog entry for name, or nil if there is none
+func (h *host) lookup(name string) (*catalogEntry, error) {
+	entries, err := h.catalog()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseArg converts a command line argument to the Go value passed to the
+// plugin. String arguments are taken literally, anything else is parsed as
+// JSON so that numbers, lists and maps can be given.
+func parseArg(raw, typ string) (interface{}, error) {
+	if typ == "string" {
+		return raw, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		if typ == "" || typ == "any" {
+			return raw, nil
+		}
+		return nil, fmt.Errorf("invalid %s argument %q, expected JSON", typ, raw)
+	}
+	return fromJSON(v), nil
+}
+
+// fromJSON converts json.Number values into int64 or float64, as Sentinel
+// would pass them
+func fromJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		for i := range x {
+			x[i] = fromJSON(x[i])
+		}
+	case map[string]interface{}:
+		for k := range x {
+			x[k] = fromJSON(x[k])
+		}
+	}
+	return v
+}
+
+// valueToJSON converts a Sentinel value into something encoding/json can
+// print. Both null and undefined become JSON null; callers that care about
+// the difference check the top-level type first.
+func valueToJSON(v *proto.Value) interface{} {
+	switch v.Type {
+	case proto.Value_BOOL:
+		return v.GetValueBool()
+	case proto.Value_INT:
+		return v.GetValueInt()
+	case proto.Value_FLOAT:
+		return v.GetValueFloat()
+	case proto.Value_STRING:
+		return v.GetValueString()
+	case proto.Value_LIST:
+		elems := v.GetValueList().GetElems()
+		list := make([]interface{}, len(elems))
+		for i, e := range elems {
+			list[i] = valueToJSON(e)
+		}
+		return list
+	case proto.Value_MAP:
+		m := make(map[string]interface{})
+		for _, kv := range v.GetValueMap().GetElems() {
+			key := valueToJSON(kv.Key)
+			if s, ok := key.(string); ok {
+				m[s] = valueToJSON(kv.Value)
+			} else {
+				m[fmt.Sprint(key)] = valueToJSON(kv.Value)
+			}
+		}
+		return m
+	}
+	return nil
+}
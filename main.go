@@ -1,17 +1,21 @@
 package main
 
 import (
+	"os"
+
 	"sentinel-plugin-demo/plugin"
 
-	sdk "github.com/hashicorp/sentinel-sdk"
-	"github.com/hashicorp/sentinel-sdk/framework"
 	"github.com/hashicorp/sentinel-sdk/rpc"
 )
 
 func main() {
+	// With no arguments, or when launched by Sentinel, serve the plugin.
+	// Otherwise run a CLI command, see cli.go.
+	if len(os.Args) > 1 && os.Getenv(rpc.Handshake.MagicCookieKey) == "" {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	rpc.Serve(&rpc.ServeOpts{
-		PluginFunc: func() sdk.Plugin {
-			return &framework.Plugin{Root: &plugin.Root{}}
-		},
+		PluginFunc: plugin.New,
 	})
 }
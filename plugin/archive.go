@@ -0,0 +1,351 @@
+package plugin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic numbers used to detect the archive format from its contents
+var (
+	magicZip      = []byte("PK\x03\x04")
+	magicZipEmpty = []byte("PK\x05\x06")
+	magicGzip     = []byte{0x1f, 0x8b}
+	magicZstd     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Return structs
+type archiveEntry struct {
+	Name     string
+	Type     string // file, dir, symlink or other
+	Size     int64
+	Mode     string
+	Modified string // RFC 3339
+}
+
+// archiveMember is an entry along with a way to read its contents. For
+// tar archives open is only valid until the walk moves to the next entry.
+type archiveMember struct {
+	archiveEntry
+	open func() (io.Reader, error)
+}
+
+// readGzipFile reads a gzip compressed file, applying max_file_bytes to
+// both the compressed file and the decompressed contents.
+func (r *Root) readGzipFile(path string) ([]byte, error) {
+	data, err := r.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidArchive(path, err)
+	}
+	defer zr.Close()
+
+	contents, err := readLimited(zr, r.conf().MaxFileBytes, path)
+	if err != nil && errorKind(err) == "" {
+		return nil, invalidArchive(path, err)
+	}
+	return contents, err
+}
+
+// listArchive returns every entry of the zip or tar archive at path.
+// Archives containing entries that would escape the extraction directory
+// are rejected outright, since no policy should trust them.
+func (r *Root) listArchive(path string) ([]*archiveEntry, error) {
+	return withTimeout(r.conf().IOTimeout, path, func() ([]*archiveEntry, error) {
+		entries := []*archiveEntry{}
+		err := r.walkArchive(path, func(m *archiveMember) (bool, error) {
+			entries = append(entries, &m.archiveEntry)
+			return false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return entries, nil
+	})
+}
+
+// readArchiveMember returns the contents of a single regular file inside
+// the archive at path, or nil if there is no such file.
+func (r *Root) readArchiveMember(path, member string) ([]byte, error) {
+	name, err := cleanMemberName(member)
+	if err != nil {
+		return nil, &kindError{Kind: kindUnsafeMember, Path: member, Err: err}
+	}
+
+	return withTimeout(r.conf().IOTimeout, path, func() ([]byte, error) {
+		var contents []byte
+		err := r.walkArchive(path, func(m *archiveMember) (bool, error) {
+			if m.Name != name || m.Type != "file" {
+				return false, nil
+			}
+
+			src, err := m.open()
+			if err != nil {
+				return true, invalidArchive(path, err)
+			}
+			contents, err = readLimited(src, r.conf().MaxFileBytes, path+"!"+name)
+			if err != nil && errorKind(err) == "" {
+				err = invalidArchive(path, err)
+			}
+			return true, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return contents, nil
+	})
+}
+
+// decompressLimit wraps the decompressed stream of a tar.gz or tar.zst,
+// failing once it yields more than max_file_bytes or runs past io_timeout.
+// A small archive can expand to gigabytes of tar data, all of which is
+// read just to list it.
+type decompressLimit struct {
+	src      io.Reader
+	path     string
+	left     int64
+	limit    int64
+	deadline time.Time
+}
+
+func (r *Root) newDecompressLimit(src io.Reader, path string) *decompressLimit {
+	c := r.conf()
+	return &decompressLimit{
+		src:      src,
+		path:     path,
+		left:     c.MaxFileBytes,
+		limit:    c.MaxFileBytes,
+		deadline: time.Now().Add(c.IOTimeout),
+	}
+}
+
+func (d *decompressLimit) Read(p []byte) (int, error) {
+	if time.Now().After(d.deadline) {
+		return 0, &kindError{Kind: kindTimeout, Path: d.path, Err: errors.New("decompression exceeded io_timeout")}
+	}
+	if int64(len(p)) > d.left+1 {
+		p = p[:d.left+1]
+	}
+	n, err := d.src.Read(p)
+	d.left -= int64(n)
+	if d.left < 0 {
+		return 0, &kindError{
+			Kind: kindTooLarge,
+			Path: d.path,
+			Err:  fmt.Errorf("decompresses to more than max_file_bytes (%d)", d.limit),
+		}
+	}
+	return n, err
+}
+
+// walkArchive calls fn for each entry of the archive at path until fn
+// returns true or an error. The format is detected from the contents:
+// zip, or tar optionally compressed with gzip or zstd. The decompressed
+// tar stream is limited by decompressLimit.
+func (r *Root) walkArchive(path string, fn func(*archiveMember) (bool, error)) error {
+	data, err := r.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, magicZip), bytes.HasPrefix(data, magicZipEmpty):
+		return walkZip(path, data, fn)
+
+	case bytes.HasPrefix(data, magicGzip):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return invalidArchive(path, err)
+		}
+		defer zr.Close()
+		return walkTar(path, r.newDecompressLimit(zr, path), fn)
+
+	case bytes.HasPrefix(data, magicZstd):
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return invalidArchive(path, err)
+		}
+		defer zr.Close()
+		return walkTar(path, r.newDecompressLimit(zr, path), fn)
+	}
+
+	return walkTar(path, bytes.NewReader(data), fn)
+}
+
+func walkZip(path string, data []byte, fn func(*archiveMember) (bool, error)) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return invalidArchive(path, err)
+	}
+
+	for _, f := range zr.File {
+		m, err := newArchiveMember(path, f.Name, f.FileInfo())
+		if err != nil {
+			return err
+		}
+		m.open = func() (io.Reader, error) {
+			return f.Open()
+		}
+
+		if stop, err := fn(m); stop || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(path string, src io.Reader, fn func(*archiveMember) (bool, error)) error {
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if errorKind(err) != "" {
+			return err
+		}
+		if err != nil {
+			return invalidArchive(path, err)
+		}
+
+		// Skip PAX global headers, they describe the archive and not a member
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		m, err := newArchiveMember(path, hdr.Name, hdr.FileInfo())
+		if err != nil {
+			return err
+		}
+		m.open = func() (io.Reader, error) {
+			return tr, nil
+		}
+
+		if stop, err := fn(m); stop || err != nil {
+			return err
+		}
+	}
+}
+
+func newArchiveMember(path, name string, info fs.FileInfo) (*archiveMember, error) {
+	clean, err := cleanMemberName(name)
+	if err != nil {
+		return nil, &kindError{Kind: kindUnsafeMember, Path: path, Err: fmt.Errorf("%q: %s", name, err)}
+	}
+
+	return &archiveMember{
+		archiveEntry: archiveEntry{
+			Name:     clean,
+			Type:     fileType(info.Mode()),
+			Size:     info.Size(),
+			Mode:     fmt.Sprintf("%04o", info.Mode().Perm()),
+			Modified: info.ModTime().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// fileType names the type of a file the way it is reported to Sentinel
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "other"
+}
+
+// cleanMemberName normalizes an archive member name to a slash separated
+// relative path, rejecting names that are absolute or climb out of the
+// extraction directory (zip-slip).
+func cleanMemberName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", errors.New("absolute member path")
+	}
+
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.New("member path escapes the archive root")
+	}
+	if clean == "." {
+		return "", errors.New("empty member path")
+	}
+	return clean, nil
+}
+
+func invalidArchive(path string, err error) error {
+	return &kindError{Kind: kindInvalidArchive, Path: path, Err: err}
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "getfile_gz",
+			Args:        []argSpec{{"path", "string"}},
+			Returns:     "string",
+			Description: "Decompressed contents of a gzip file, or undefined if it cannot be read. Same limits as getfile.",
+			Example:     `pd.getfile_gz("./plan.json.gz")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path string) (interface{}, error) {
+					return r.fileResult(r.readGzipFile(path))
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "archive_list",
+			Args:        []argSpec{{"path", "string"}},
+			Returns:     "list(map)",
+			Description: "Entries of a zip, tar, tar.gz or tar.zst archive with name, type, size, mode and modified time.",
+			Example:     `pd.archive_list("./bundle.tar.gz")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			Shape:       []*archiveEntry(nil),
+			New: func(r *Root) interface{} {
+				return func(path string) (interface{}, error) {
+					entries, err := r.listArchive(path)
+					if err != nil {
+						return nil, r.fileError(err)
+					}
+					return entries, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "archive_read",
+			Args:        []argSpec{{"path", "string"}, {"member", "string"}},
+			Returns:     "string",
+			Description: "Contents of a single file inside an archive, or undefined if the archive or member does not exist.",
+			Example:     `pd.archive_read("./bundle.tar.gz", "main.tf")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path string, member string) (interface{}, error) {
+					contents, err := r.readArchiveMember(path, member)
+					if err == nil && contents == nil {
+						return nil, nil
+					}
+					return r.fileResult(contents, err)
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,275 @@
+package plugin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// testMembers are written into every archive built by the tests
+var testMembers = map[string]string{
+	"config/main.tf": "resource \"null_resource\" \"x\" {}",
+	"README.md":      "hello",
+}
+
+func writeTestZip(t *testing.T, path string, names map[string]string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip member: %v", err)
+		}
+		io.WriteString(w, body)
+	}
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write zip: %v", err)
+	}
+}
+
+func writeTestTar(t *testing.T, path string, names map[string]string, compress func(io.Writer) io.WriteCloser) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var cw io.WriteCloser
+	if compress != nil {
+		cw = compress(&buf)
+		w = cw
+	}
+
+	tw := tar.NewWriter(w)
+	for name, body := range names {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		io.WriteString(tw, body)
+	}
+	tw.Close()
+	if cw != nil {
+		cw.Close()
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write tar: %v", err)
+	}
+}
+
+func TestArchives(t *testing.T) {
+	root := &Root{}
+	dir := t.TempDir()
+
+	archives := map[string]string{
+		"zip":     filepath.Join(dir, "bundle.zip"),
+		"tar":     filepath.Join(dir, "bundle.tar"),
+		"tar.gz":  filepath.Join(dir, "bundle.tar.gz"),
+		"tar.zst": filepath.Join(dir, "bundle.tar.zst"),
+	}
+	writeTestZip(t, archives["zip"], testMembers)
+	writeTestTar(t, archives["tar"], testMembers, nil)
+	writeTestTar(t, archives["tar.gz"], testMembers, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	})
+	writeTestTar(t, archives["tar.zst"], testMembers, func(w io.Writer) io.WriteCloser {
+		zw, _ := zstd.NewWriter(w)
+		return zw
+	})
+
+	// Test that archive_list returns every member for each format
+	t.Run("ListsMembers", func(t *testing.T) {
+		callable := root.Func("archive_list").(func(string) (interface{}, error))
+		for format, path := range archives {
+			result, err := callable(path)
+			if err != nil {
+				t.Fatalf("%s: archive_list should not return error: %v", format, err)
+			}
+
+			entries := result.([]*archiveEntry)
+			if len(entries) != len(testMembers) {
+				t.Fatalf("%s: expected %d entries, got %d", format, len(testMembers), len(entries))
+			}
+			for _, e := range entries {
+				if _, ok := testMembers[e.Name]; !ok {
+					t.Errorf("%s: unexpected entry %s", format, e.Name)
+				}
+				if e.Type != "file" {
+					t.Errorf("%s: expected %s to be a file, got %s", format, e.Name, e.Type)
+				}
+			}
+		}
+	})
+
+	// Test that archive_read returns member contents for each format
+	t.Run("ReadsMembers", func(t *testing.T) {
+		callable := root.Func("archive_read").(func(string, string) (interface{}, error))
+		for format, path := range archives {
+			result, err := callable(path, "./config/main.tf")
+			if err != nil {
+				t.Fatalf("%s: archive_read should not return error: %v", format, err)
+			}
+			if result == nil {
+				t.Fatalf("%s: archive_read should find config/main.tf", format)
+			}
+			if *result.(*string) != testMembers["config/main.tf"] {
+				t.Errorf("%s: unexpected contents %q", format, *result.(*string))
+			}
+		}
+	})
+
+	// Test that archive_read returns nil for missing members
+	t.Run("ReturnsNilForMissingMember", func(t *testing.T) {
+		callable := root.Func("archive_read").(func(string, string) (interface{}, error))
+		result, err := callable(archives["zip"], "missing.txt")
+		if result != nil || err != nil {
+			t.Errorf("Expected nil, nil for missing member, got %v, %v", result, err)
+		}
+	})
+
+	// Test that archive_list returns nil for missing archives
+	t.Run("ReturnsNilForMissingArchive", func(t *testing.T) {
+		callable := root.Func("archive_list").(func(string) (interface{}, error))
+		result, err := callable(filepath.Join(dir, "missing.zip"))
+		if result != nil || err != nil {
+			t.Errorf("Expected nil, nil for missing archive, got %v, %v", result, err)
+		}
+	})
+
+	// Test that archives with escaping member names are rejected
+	t.Run("RejectsZipSlip", func(t *testing.T) {
+		path := filepath.Join(dir, "slip.tar")
+		writeTestTar(t, path, map[string]string{"../../etc/passwd": "root"}, nil)
+
+		callable := root.Func("archive_list").(func(string) (interface{}, error))
+		_, err := callable(path)
+		if errorKind(err) != kindUnsafeMember {
+			t.Errorf("Expected %s error, got %v", kindUnsafeMember, err)
+		}
+
+		read := root.Func("archive_read").(func(string, string) (interface{}, error))
+		_, err = read(archives["zip"], "../README.md")
+		if errorKind(err) != kindUnsafeMember {
+			t.Errorf("Expected %s error, got %v", kindUnsafeMember, err)
+		}
+	})
+
+	// Test that member reads honour max_file_bytes
+	t.Run("EnforcesSizeLimit", func(t *testing.T) {
+		path := filepath.Join(dir, "big.zip")
+		writeTestZip(t, path, map[string]string{"big.txt": string(bytes.Repeat([]byte("a"), 4096))})
+
+		limited := &Root{}
+		limited.Configure(map[string]interface{}{"max_file_bytes": int64(2048)})
+		callable := limited.Func("archive_read").(func(string, string) (interface{}, error))
+		_, err := callable(path, "big.txt")
+		if errorKind(err) != kindTooLarge {
+			t.Errorf("Expected %s error, got %v", kindTooLarge, err)
+		}
+	})
+
+	// Test that a small archive expanding past max_file_bytes is rejected
+	// without decompressing all of it
+	t.Run("RejectsDecompressionBomb", func(t *testing.T) {
+		zeros := string(make([]byte, 64<<20))
+		bombs := map[string]string{
+			"tar.gz":  filepath.Join(dir, "bomb.tar.gz"),
+			"tar.zst": filepath.Join(dir, "bomb.tar.zst"),
+		}
+		writeTestTar(t, bombs["tar.gz"], map[string]string{"zeros": zeros}, func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		})
+		writeTestTar(t, bombs["tar.zst"], map[string]string{"zeros": zeros}, func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		})
+
+		limited := &Root{}
+		limited.Configure(map[string]interface{}{"log_level": "off", "max_file_bytes": int64(1 << 20), "io_timeout": "5s"})
+		list := limited.Func("archive_list").(func(string) (interface{}, error))
+		read := limited.Func("archive_read").(func(string, string) (interface{}, error))
+		for format, path := range bombs {
+			start := time.Now()
+			if _, err := list(path); errorKind(err) != kindTooLarge {
+				t.Errorf("%s: expected %s error from archive_list, got %v", format, kindTooLarge, err)
+			}
+			if _, err := read(path, "missing"); errorKind(err) != kindTooLarge {
+				t.Errorf("%s: expected %s error from archive_read, got %v", format, kindTooLarge, err)
+			}
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("%s: took %s, expected to stop at the limit", format, d)
+			}
+		}
+	})
+
+	// Test that corrupt archives return an invalid_archive error
+	t.Run("RejectsCorruptArchive", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupt.tar")
+		os.WriteFile(path, []byte("not an archive"), 0644)
+
+		callable := root.Func("archive_list").(func(string) (interface{}, error))
+		_, err := callable(path)
+		if errorKind(err) != kindInvalidArchive {
+			t.Errorf("Expected %s error, got %v", kindInvalidArchive, err)
+		}
+	})
+}
+
+func TestGetFileGz(t *testing.T) {
+	root := &Root{}
+	dir := t.TempDir()
+
+	// Test that getfile_gz returns the decompressed contents
+	t.Run("ReadsGzipFile", func(t *testing.T) {
+		path := filepath.Join(dir, "plan.json.gz")
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		io.WriteString(zw, `{"format_version":"1.2"}`)
+		zw.Close()
+		os.WriteFile(path, buf.Bytes(), 0644)
+
+		callable := root.Func("getfile_gz").(func(string) (interface{}, error))
+		result, err := callable(path)
+		if err != nil {
+			t.Fatalf("getfile_gz should not return error: %v", err)
+		}
+		if *result.(*string) != `{"format_version":"1.2"}` {
+			t.Errorf("Unexpected contents %q", *result.(*string))
+		}
+	})
+
+	// Test that plain files are rejected as invalid
+	t.Run("RejectsPlainFile", func(t *testing.T) {
+		path := filepath.Join(dir, "plain.txt")
+		os.WriteFile(path, []byte("plain"), 0644)
+
+		callable := root.Func("getfile_gz").(func(string) (interface{}, error))
+		_, err := callable(path)
+		if errorKind(err) != kindInvalidArchive {
+			t.Errorf("Expected %s error, got %v", kindInvalidArchive, err)
+		}
+	})
+}
+
+func TestCleanMemberName(t *testing.T) {
+	valid := map[string]string{
+		"a/b.txt":     "a/b.txt",
+		"./a/../b":    "b",
+		"dir/":        "dir",
+		"win\\path.x": "win/path.x",
+	}
+	for in, want := range valid {
+		got, err := cleanMemberName(in)
+		if err != nil || got != want {
+			t.Errorf("cleanMemberName(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"/etc/passwd", "../x", "a/../../x", "C:\\x", ".", ""} {
+		if _, err := cleanMemberName(in); err == nil {
+			t.Errorf("cleanMemberName(%q) should return error", in)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit decisions
+const (
+	auditAllowed = "allowed"
+	auditDenied  = "denied"
+)
+
+// auditRecord is one line of the audit log
+type auditRecord struct {
+	Time     string `json:"time"`
+	RunID    string `json:"run_id,omitempty"`
+	Source   string `json:"source"` // env or file
+	Target   string `json:"target"` // variable name, "*" for all, or path
+	Decision string `json:"decision"`
+	Error    string `json:"error,omitempty"`
+}
+
+// auditLog appends records to a JSON Lines file, rotating it once it
+// would grow past maxBytes: audit.jsonl becomes audit.jsonl.1, .1
+// becomes .2 and so on, keeping at most maxBackups old files.
+type auditLog struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newAuditLog(path string, maxBytes int64, maxBackups int) *auditLog {
+	return &auditLog{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+}
+
+// write appends rec, opening or rotating the file as needed
+func (a *auditLog) write(rec *auditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f != nil && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	if a.f == nil {
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := a.f.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.f, a.size = f, info.Size()
+	return nil
+}
+
+func (a *auditLog) rotate() error {
+	a.f.Close()
+	a.f = nil
+
+	if a.maxBackups < 1 {
+		return os.Remove(a.path)
+	}
+	for i := a.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(a.path, a.path+".1")
+}
+
+// Close closes the current file, if open
+func (a *auditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// audit records an access to an environment variable or file. err is the
+// result of the access: permission errors and plugin limits count as
+// denied, anything else (including a missing file) as allowed.
+func (r *Root) audit(source, target string, err error) {
+	if r.auditLog == nil {
+		return
+	}
+
+	rec := &auditRecord{
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		RunID:    os.Getenv("TFC_RUN_ID"),
+		Source:   source,
+		Target:   target,
+		Decision: auditAllowed,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+		if errors.Is(err, fs.ErrPermission) || errorKind(err) != "" {
+			rec.Decision = auditDenied
+		}
+	}
+
+	if werr := r.auditLog.write(rec); werr != nil {
+		r.log().Error("failed to write audit log", "path", r.auditLog.path, "error", werr)
+	}
+}
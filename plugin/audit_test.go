@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAuditRecords(t *testing.T, path string) []*auditRecord {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var records []*auditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Audit line is not valid JSON: %s", scanner.Text())
+		}
+		records = append(records, &rec)
+	}
+	return records
+}
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	auditFile := filepath.Join(dir, "audit.jsonl")
+
+	root := &Root{}
+	err := root.Configure(map[string]interface{}{
+		"audit_file":     auditFile,
+		"max_file_bytes": int64(4),
+		"log_level":      "off",
+	})
+	if err != nil {
+		t.Fatalf("Configure should not return error: %v", err)
+	}
+	defer root.auditLog.Close()
+
+	os.Setenv("TFC_RUN_ID", "run-abc123")
+	defer os.Unsetenv("TFC_RUN_ID")
+
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.txt")
+	os.WriteFile(small, []byte("ok"), 0644)
+	os.WriteFile(large, []byte("too large"), 0644)
+
+	root.Func("getenv").(func(string) interface{})("HOME")
+	root.Func("getallenvs").(func() interface{})()
+	root.Get("envs")
+	getfile := root.Func("getfile").(func(string) (interface{}, error))
+	getfile(small)
+	getfile(large)
+	getfile(filepath.Join(dir, "missing.txt"))
+
+	records := readAuditRecords(t, auditFile)
+
+	// Test that every access was recorded in order
+	t.Run("RecordsEveryAccess", func(t *testing.T) {
+		want := []struct{ source, target, decision string }{
+			{"env", "HOME", auditAllowed},
+			{"env", "*", auditAllowed},
+			{"env", "*", auditAllowed},
+			{"file", small, auditAllowed},
+			{"file", large, auditDenied},
+			{"file", filepath.Join(dir, "missing.txt"), auditAllowed},
+		}
+		if len(records) != len(want) {
+			t.Fatalf("Expected %d records, got %d", len(want), len(records))
+		}
+		for i, w := range want {
+			r := records[i]
+			if r.Source != w.source || r.Target != w.target || r.Decision != w.decision {
+				t.Errorf("Record %d: expected %s %s %s, got %s %s %s", i, w.source, w.target, w.decision, r.Source, r.Target, r.Decision)
+			}
+		}
+	})
+
+	// Test that records carry the run ID, time and errors
+	t.Run("RecordsContext", func(t *testing.T) {
+		for _, r := range records {
+			if r.RunID != "run-abc123" || r.Time == "" {
+				t.Errorf("Expected run ID and time, got %+v", r)
+			}
+		}
+		if records[4].Error == "" || records[5].Error == "" {
+			t.Error("Failed reads should record their error")
+		}
+	})
+}
+
+func TestAuditRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	a := newAuditLog(path, 200, 2)
+	defer a.Close()
+
+	// Write enough records to rotate several times
+	for i := 0; i < 20; i++ {
+		if err := a.write(&auditRecord{Source: "env", Target: "KEY", Decision: auditAllowed}); err != nil {
+			t.Fatalf("write should not return error: %v", err)
+		}
+	}
+
+	// Test that the current file stays under the limit
+	t.Run("RespectsMaxBytes", func(t *testing.T) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat audit log: %v", err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("Expected at most 200 bytes, got %d", info.Size())
+		}
+	})
+
+	// Test that only max_backups rotated files are kept
+	t.Run("KeepsMaxBackups", func(t *testing.T) {
+		for _, suffix := range []string{".1", ".2"} {
+			if _, err := os.Stat(path + suffix); err != nil {
+				t.Errorf("Expected %s to exist", path+suffix)
+			}
+		}
+		if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+			t.Error("Expected no third backup")
+		}
+	})
+}
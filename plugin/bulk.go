@@ -0,0 +1,256 @@
+package plugin
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hash algorithms supported by hashfiles
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Return structs
+type bulkFile struct {
+	Contents  string
+	Error     string
+	ErrorKind string
+}
+
+type bulkHash struct {
+	Hash      string
+	Error     string
+	ErrorKind string
+}
+
+type bulkStat struct {
+	Exists    bool
+	Type      string // file, dir, symlink or other
+	Size      int64
+	Mode      string
+	Modified  string // RFC 3339
+	Error     string
+	ErrorKind string
+}
+
+// forEachPath calls fn for every distinct path on a pool of bulk_workers
+// goroutines and returns once all calls are done. fn must be safe for
+// concurrent use.
+func (r *Root) forEachPath(paths []string, fn func(path string)) {
+	seen := make(map[string]bool, len(paths))
+	work := make(chan string)
+
+	var wg sync.WaitGroup
+	workers := r.conf().BulkWorkers
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				fn(path)
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			work <- path
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+// bulkRun runs fn for every path concurrently and collects the results
+// into a map keyed by path
+func bulkRun[T any](r *Root, paths []string, fn func(path string) T) map[string]T {
+	var mu sync.Mutex
+	results := make(map[string]T, len(paths))
+	r.forEachPath(paths, func(path string) {
+		v := fn(path)
+		mu.Lock()
+		results[path] = v
+		mu.Unlock()
+	})
+	return results
+}
+
+// errorFields returns the message and kind of err for a per-path result
+func errorFields(err error) (string, string) {
+	if err == nil {
+		return "", ""
+	}
+	return err.Error(), errorKind(err)
+}
+
+func (r *Root) getFiles(paths []string) map[string]*bulkFile {
+	return bulkRun(r, paths, func(path string) *bulkFile {
+		contents, err := r.readFile(path)
+		res := &bulkFile{Contents: string(contents)}
+		res.Error, res.ErrorKind = errorFields(err)
+		return res
+	})
+}
+
+func (r *Root) hashFiles(paths []string, algo string) (map[string]*bulkHash, error) {
+	newHash, ok := hashAlgorithms[strings.ToLower(algo)]
+	if !ok {
+		return nil, &kindError{
+			Kind: kindInvalidArgument,
+			Err:  fmt.Errorf("unknown hash algorithm %q, expected one of %s", algo, strings.Join(sortedKeys(hashAlgorithms), ", ")),
+		}
+	}
+
+	return bulkRun(r, paths, func(path string) *bulkHash {
+		sum, err := r.hashFile(path, newHash)
+		res := &bulkHash{Hash: sum}
+		res.Error, res.ErrorKind = errorFields(err)
+		return res
+	}), nil
+}
+
+// hashFile streams path through a new hash and returns its hex digest.
+// Nothing is buffered, so files over max_file_bytes can be hashed; the
+// whole read is still bounded by io_timeout.
+func (r *Root) hashFile(path string, newHash func() hash.Hash) (string, error) {
+	type digest struct {
+		sum  []byte
+		read int64
+	}
+	d, err := withTimeout(r.conf().IOTimeout, path, func() (*digest, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return &digest{}, err
+		}
+		defer f.Close()
+
+		h := newHash()
+		n, err := io.Copy(h, f)
+		if err != nil {
+			return &digest{read: n}, err
+		}
+		return &digest{sum: h.Sum(nil), read: n}, nil
+	})
+	r.audit("file", path, err)
+	if d != nil {
+		r.stats().addBytesRead(int(d.read))
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(d.sum), nil
+}
+
+func (r *Root) statMany(paths []string) map[string]*bulkStat {
+	timeout := r.conf().IOTimeout
+	return bulkRun(r, paths, func(path string) *bulkStat {
+		info, err := withTimeout(timeout, path, func() (fs.FileInfo, error) {
+			return os.Stat(path)
+		})
+		r.audit("file", path, err)
+
+		res := &bulkStat{}
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			res.Error, res.ErrorKind = errorFields(err)
+		default:
+			res.Exists = true
+			res.Type = fileType(info.Mode())
+			res.Size = info.Size()
+			res.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+			res.Modified = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		return res
+	})
+}
+
+// toPaths checks that every element of a list argument is a path. Lists
+// from Sentinel arrive as []interface{}, and a single bad element should
+// fail the call rather than silently drop out of the result.
+func toPaths(list []interface{}) ([]string, error) {
+	paths := make([]string, len(list))
+	for i, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("paths[%d]: expected a string, got %T", i, v)}
+		}
+		paths[i] = s
+	}
+	return paths, nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "getfiles",
+			Args:        []argSpec{{"paths", "list(string)"}},
+			Returns:     "map(map)",
+			Description: "Contents of many files read concurrently, keyed by path. Files that cannot be read have error and error_kind set instead of contents.",
+			Example:     `pd.getfiles(["./a.tf", "./b.tf"])["./a.tf"].contents`,
+			Shape:       map[string]*bulkFile(nil),
+			New: func(r *Root) interface{} {
+				return func(list []interface{}) (interface{}, error) {
+					paths, err := toPaths(list)
+					if err != nil {
+						return nil, err
+					}
+					return r.getFiles(paths), nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "hashfiles",
+			Args:        []argSpec{{"paths", "list(string)"}, {"algo", "string"}},
+			Returns:     "map(map)",
+			Description: "Hex digests of many files computed concurrently, keyed by path. algo is md5, sha1, sha256 or sha512. Files are streamed, so max_file_bytes does not apply.",
+			Example:     `pd.hashfiles(["./plugin.zip"], "sha256")["./plugin.zip"].hash`,
+			Shape:       map[string]*bulkHash(nil),
+			New: func(r *Root) interface{} {
+				return func(list []interface{}, algo string) (interface{}, error) {
+					paths, err := toPaths(list)
+					if err != nil {
+						return nil, err
+					}
+					return r.hashFiles(paths, algo)
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "statmany",
+			Args:        []argSpec{{"paths", "list(string)"}},
+			Returns:     "map(map)",
+			Description: "Whether each file exists, with its type, size, mode and modified time, keyed by path. Files are stat'ed concurrently.",
+			Example:     `pd.statmany(["./main.tf"])["./main.tf"].exists`,
+			Shape:       map[string]*bulkStat(nil),
+			New: func(r *Root) interface{} {
+				return func(list []interface{}) (interface{}, error) {
+					paths, err := toPaths(list)
+					if err != nil {
+						return nil, err
+					}
+					return r.statMany(paths), nil
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBulkFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	large := filepath.Join(dir, "large.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	os.WriteFile(a, []byte("hello"), 0644)
+	os.WriteFile(b, []byte("world"), 0600)
+	os.WriteFile(large, []byte("more than ten bytes"), 0644)
+
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off", "max_file_bytes": int64(10), "bulk_workers": int64(2)})
+	paths := []interface{}{a, b, large, missing, a}
+
+	// Test that getfiles returns contents or a per-path error
+	t.Run("GetFiles", func(t *testing.T) {
+		result, err := root.Func("getfiles").(func([]interface{}) (interface{}, error))(paths)
+		if err != nil {
+			t.Fatalf("getfiles should not return error: %v", err)
+		}
+
+		files := result.(map[string]*bulkFile)
+		if len(files) != 4 {
+			t.Errorf("Expected 4 distinct paths, got %d", len(files))
+		}
+		if files[a].Contents != "hello" || files[b].Contents != "world" {
+			t.Errorf("Unexpected contents %+v, %+v", files[a], files[b])
+		}
+		if files[large].ErrorKind != kindTooLarge {
+			t.Errorf("Expected too_large for %s, got %+v", large, files[large])
+		}
+		if files[missing].Error == "" || files[missing].ErrorKind != "" {
+			t.Errorf("Expected a plain error for %s, got %+v", missing, files[missing])
+		}
+	})
+
+	// Test that hashfiles returns hex digests
+	t.Run("HashFiles", func(t *testing.T) {
+		hashfiles := root.Func("hashfiles").(func([]interface{}, string) (interface{}, error))
+		result, err := hashfiles(paths, "SHA256")
+		if err != nil {
+			t.Fatalf("hashfiles should not return error: %v", err)
+		}
+
+		hashes := result.(map[string]*bulkHash)
+		want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+		if hashes[a].Hash != want {
+			t.Errorf("Expected %s, got %+v", want, hashes[a])
+		}
+		want = "67394cdcda12ba726ae1951b2db1f1d5f78ff97c054ecd8ed94952758b0fbdd6"
+		if hashes[large].Hash != want || hashes[large].Error != "" {
+			t.Errorf("Expected %s for a file over max_file_bytes, got %+v", want, hashes[large])
+		}
+		if hashes[missing].Hash != "" || hashes[missing].Error == "" {
+			t.Errorf("Expected an error for %s, got %+v", missing, hashes[missing])
+		}
+
+		if _, err := hashfiles(paths, "crc32"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+
+	// Test that statmany reports existence and metadata
+	t.Run("StatMany", func(t *testing.T) {
+		result, err := root.Func("statmany").(func([]interface{}) (interface{}, error))(append(paths, dir))
+		if err != nil {
+			t.Fatalf("statmany should not return error: %v", err)
+		}
+
+		stats := result.(map[string]*bulkStat)
+		if s := stats[b]; !s.Exists || s.Type != "file" || s.Size != 5 || s.Mode != "0600" {
+			t.Errorf("Unexpected stat for %s: %+v", b, s)
+		}
+		if s := stats[dir]; !s.Exists || s.Type != "dir" {
+			t.Errorf("Unexpected stat for %s: %+v", dir, s)
+		}
+		if s := stats[missing]; s.Exists || s.Error != "" {
+			t.Errorf("Expected %s to not exist without an error, got %+v", missing, s)
+		}
+	})
+
+	// Test that non-string paths are rejected
+	t.Run("RejectsNonStringPaths", func(t *testing.T) {
+		_, err := root.Func("statmany").(func([]interface{}) (interface{}, error))([]interface{}{a, int64(1)})
+		if errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+}
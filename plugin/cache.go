@@ -0,0 +1,239 @@
+package plugin
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachePolicy says whether and how results of a function or property may
+// be reused, see funcSpec.Cache
+type cachePolicy int
+
+const (
+	// cacheNever recomputes on every call
+	cacheNever cachePolicy = iota
+
+	// cacheByArgs reuses results for the same arguments for the lifetime
+	// of the plugin, for values that cannot change while it runs such as
+	// its environment
+	cacheByArgs
+
+	// cacheByFile reuses results for the same arguments as long as the
+	// file named by the first argument keeps its size and modification
+	// time
+	cacheByFile
+)
+
+// callCache is a least recently used cache of call results, shared by
+// every function and property with a cache policy. It is safe for
+// concurrent use.
+type callCache struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+
+	hits          int64
+	misses        int64
+	evictions     int64
+	invalidations int64
+}
+
+type cacheEntry struct {
+	key   string
+	out   []reflect.Value
+	stamp *fileStamp // set for cacheByFile
+}
+
+// fileStamp identifies the version of a file a result was computed from
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func newCallCache(capacity int) *callCache {
+	return &callCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// get returns the cached result for key. stamp is the file's current
+// stamp for cacheByFile entries; a mismatch drops the entry.
+func (c *callCache) get(key string, stamp *fileStamp) ([]reflect.Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if e.stamp != nil && (stamp == nil || *e.stamp != *stamp) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		c.invalidations++
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return e.out, true
+}
+
+// put stores a result, evicting the least recently used entry if full
+func (c *callCache) put(key string, out []reflect.Value, stamp *fileStamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &cacheEntry{key, out, stamp}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, out, stamp})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Return structs
+type cacheStats struct {
+	Enabled       bool
+	Size          int
+	Capacity      int
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Invalidations int64
+}
+
+func (c *callCache) stats() *cacheStats {
+	if c == nil {
+		return &cacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &cacheStats{
+		Enabled:       true,
+		Size:          c.order.Len(),
+		Capacity:      c.capacity,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+	}
+}
+
+// statFile returns the stamp of the file at path, or nil if it cannot be
+// stat'ed, in which case the result is not cached
+func statFile(path string) *fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return &fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// cacheKey identifies a call by name and arguments
+func cacheKey(name string, args []reflect.Value) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, a := range args {
+		fmt.Fprintf(&b, "\x00%#v", a.Interface())
+	}
+	return b.String()
+}
+
+// cacheFunc wraps fn so its results are reused according to policy when
+// the cache is enabled. Calls that fail are never cached, nor are
+// undefined file results, which may hide a denied read. A hit is recorded
+// in the audit log under the audit source, as the call it replaces would
+// have been. The wrapper has the same type as fn.
+func (r *Root) cacheFunc(name string, policy cachePolicy, audit string, fn interface{}) interface{} {
+	c := r.cache
+	if c == nil || policy == cacheNever {
+		return fn
+	}
+
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	returnsErr := t.NumOut() == 2 && t.Out(1) == errorTyp
+
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		key := cacheKey("function\x00"+name, args)
+
+		var stamp *fileStamp
+		if policy == cacheByFile {
+			stamp = statFile(args[0].String())
+		}
+		if out, ok := c.get(key, stamp); ok {
+			if audit != "" {
+				target := "*"
+				if len(args) > 0 {
+					target = fmt.Sprint(args[0].Interface())
+				}
+				r.audit(audit, target, nil)
+			}
+			return out
+		}
+
+		out := v.Call(args)
+		if returnsErr && !out[1].IsNil() {
+			return out
+		}
+		if policy == cacheByFile && (stamp == nil || out[0].IsNil()) {
+			return out
+		}
+		c.put(key, out, stamp)
+		return out
+	}).Interface()
+}
+
+// cacheGet is cacheFunc for property reads
+func (r *Root) cacheGet(name string, policy cachePolicy, audit string, get func(*Root) (interface{}, error)) func(*Root) (interface{}, error) {
+	c := r.cache
+	if c == nil || policy == cacheNever {
+		return get
+	}
+
+	return func(r *Root) (interface{}, error) {
+		key := "property\x00" + name
+		if out, ok := c.get(key, nil); ok {
+			if audit != "" {
+				r.audit(audit, "*", nil)
+			}
+			return out[0].Interface(), nil
+		}
+		v, err := get(r)
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, []reflect.Value{reflect.ValueOf(&v).Elem()}, nil)
+		return v, nil
+	}
+}
+
+func init() {
+	registerProp(&propSpec{
+		Name:        "cache_stats",
+		Returns:     "map",
+		Description: "Size and hit, miss, eviction and invalidation counts of the result cache enabled by cache_size.",
+		Example:     `pd.cache_stats.hits`,
+		Shape:       (*cacheStats)(nil),
+		Get: func(r *Root) (interface{}, error) {
+			return r.cache.stats(), nil
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.txt")
+	os.WriteFile(path, []byte("first"), 0644)
+
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off", "cache_size": int64(2)})
+
+	// Test that repeated reads of an unchanged file are served from the cache
+	t.Run("ReusesFileResults", func(t *testing.T) {
+		getfile := root.Func("getfile").(func(string) (interface{}, error))
+		for i := 0; i < 3; i++ {
+			result, err := getfile(path)
+			if err != nil || *result.(*string) != "first" {
+				t.Fatalf("Expected first, got %v, %v", result, err)
+			}
+		}
+
+		stats := root.cache.stats()
+		if stats.Hits != 2 || stats.Misses != 1 {
+			t.Errorf("Expected 2 hits and 1 miss, got %+v", stats)
+		}
+		if root.stats().snapshot().BytesRead != 5 {
+			t.Error("Cache hits should not read the file again")
+		}
+	})
+
+	// Test that a changed file is read again
+	t.Run("InvalidatesChangedFiles", func(t *testing.T) {
+		os.WriteFile(path, []byte("second!"), 0644)
+		os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+
+		result, _ := root.Func("getfile").(func(string) (interface{}, error))(path)
+		if *result.(*string) != "second!" {
+			t.Errorf("Expected second!, got %q", *result.(*string))
+		}
+		if root.cache.stats().Invalidations != 1 {
+			t.Errorf("Expected 1 invalidation, got %+v", root.cache.stats())
+		}
+	})
+
+	// Test that missing files and failed calls are not cached
+	t.Run("SkipsMissingFilesAndErrors", func(t *testing.T) {
+		before := root.cache.stats().Size
+		root.Func("getfile").(func(string) (interface{}, error))(filepath.Join(dir, "missing"))
+		root.Func("decode").(func(string, string) (interface{}, error))("zz", "hex")
+		if root.cache.stats().Size != before {
+			t.Errorf("Expected size %d, got %+v", before, root.cache.stats())
+		}
+	})
+
+	// Test that the least recently used entry is evicted past cache_size
+	t.Run("EvictsLeastRecentlyUsed", func(t *testing.T) {
+		getenv := root.Func("getenv").(func(string) interface{})
+		getenv("HOME")
+		getenv("PATH")
+
+		stats := root.cache.stats()
+		if stats.Size != 2 || stats.Evictions != 1 {
+			t.Errorf("Expected 2 entries and 1 eviction, got %+v", stats)
+		}
+	})
+
+	// Test that properties with a cache policy are cached
+	t.Run("CachesProperties", func(t *testing.T) {
+		hits := root.cache.stats().Hits
+		root.Get("envs")
+		result, err := root.Get("envs")
+		if err != nil {
+			t.Fatalf("envs should not return error: %v", err)
+		}
+		if _, ok := result.(map[string]string); !ok {
+			t.Errorf("Expected map[string]string, got %T", result)
+		}
+		if root.cache.stats().Hits != hits+1 {
+			t.Error("Second read of envs should hit the cache")
+		}
+	})
+
+	// Test that cache hits are recorded in the audit log like the reads
+	// they replace
+	t.Run("AuditsHits", func(t *testing.T) {
+		auditFile := filepath.Join(dir, "audit.jsonl")
+		audited := &Root{}
+		audited.Configure(map[string]interface{}{"log_level": "off", "cache_size": int64(8), "audit_file": auditFile})
+		defer audited.auditLog.Close()
+
+		getenv := audited.Func("getenv").(func(string) interface{})
+		getfile := audited.Func("getfile").(func(string) (interface{}, error))
+		for i := 0; i < 3; i++ {
+			getenv("HOME")
+			getfile(path)
+			audited.Get("envs")
+		}
+
+		if stats := audited.cache.stats(); stats.Hits != 6 {
+			t.Errorf("Expected 6 cache hits, got %+v", stats)
+		}
+		records := readAuditRecords(t, auditFile)
+		if len(records) != 9 {
+			t.Fatalf("Expected 9 audit records, got %d", len(records))
+		}
+		for i, want := range []string{"HOME", path, "*"} {
+			for j := i; j < len(records); j += 3 {
+				if r := records[j]; r.Target != want || r.Decision != auditAllowed {
+					t.Errorf("Record %d: expected %s allowed, got %s %s", j, want, r.Target, r.Decision)
+				}
+			}
+		}
+	})
+
+	// Test that cache_stats reports a disabled cache
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		result, err := (&Root{}).Get("cache_stats")
+		if err != nil {
+			t.Fatalf("cache_stats should not return error: %v", err)
+		}
+		if stats := result.(*cacheStats); stats.Enabled {
+			t.Errorf("Expected cache to be disabled, got %+v", stats)
+		}
+	})
+}
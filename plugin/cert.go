@@ -0,0 +1,284 @@
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Return structs
+type certInfo struct {
+	Subject            string
+	CommonName         string
+	Issuer             string
+	DNSNames           []string `sentinel:"dns_names"`
+	IPAddresses        []string `sentinel:"ip_addresses"`
+	EmailAddresses     []string
+	URIs               []string `sentinel:"uris"`
+	Serial             string   // hex
+	NotBefore          string   // RFC 3339
+	NotAfter           string   // RFC 3339
+	IsCA               bool     `sentinel:"is_ca"`
+	KeyType            string   // rsa, ecdsa, ed25519 or unknown
+	KeySize            int      // bits
+	SignatureAlgorithm string
+	SHA256Fingerprint  string `sentinel:"sha256_fingerprint"`
+	SHA1Fingerprint    string `sentinel:"sha1_fingerprint"`
+}
+
+type chainResult struct {
+	Valid  bool
+	Error  string
+	Chains [][]string // subjects from the leaf to a root, for each chain found
+}
+
+// parseCertificates decodes every CERTIFICATE block in data
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, &kindError{Kind: kindDecode, Err: err}
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, &kindError{Kind: kindDecode, Err: errors.New("no PEM CERTIFICATE block found")}
+	}
+	return certs, nil
+}
+
+// parseCert describes the first certificate in data
+func parseCert(data []byte) (*certInfo, error) {
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	return newCertInfo(certs[0]), nil
+}
+
+func newCertInfo(c *x509.Certificate) *certInfo {
+	info := &certInfo{
+		Subject:            c.Subject.String(),
+		CommonName:         c.Subject.CommonName,
+		Issuer:             c.Issuer.String(),
+		DNSNames:           c.DNSNames,
+		EmailAddresses:     c.EmailAddresses,
+		Serial:             hex.EncodeToString(c.SerialNumber.Bytes()),
+		NotBefore:          c.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:           c.NotAfter.UTC().Format(time.RFC3339),
+		IsCA:               c.IsCA,
+		SignatureAlgorithm: c.SignatureAlgorithm.String(),
+	}
+	for _, ip := range c.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range c.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+
+	switch key := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeySize = "rsa", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeySize = "ecdsa", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeySize = "ed25519", 256
+	default:
+		info.KeyType = "unknown"
+	}
+
+	sha256Sum := sha256.Sum256(c.Raw)
+	sha1Sum := sha1.Sum(c.Raw)
+	info.SHA256Fingerprint = hex.EncodeToString(sha256Sum[:])
+	info.SHA1Fingerprint = hex.EncodeToString(sha1Sum[:])
+	return info
+}
+
+// verifyChain checks that the first certificate in leaf chains up to one
+// of roots through intermediates, as of at. A chain that does not verify
+// is reported through Valid and Error; input that is not PEM fails.
+func verifyChain(leaf string, intermediates, roots []string, at time.Time) (*chainResult, error) {
+	if len(roots) == 0 {
+		return nil, &kindError{Kind: kindInvalidArgument, Err: errors.New("at least one root certificate is required")}
+	}
+	leafCerts, err := parseCertificates([]byte(leaf))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := x509.VerifyOptions{
+		Intermediates: x509.NewCertPool(),
+		Roots:         x509.NewCertPool(),
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, bundle := range intermediates {
+		certs, err := parseCertificates([]byte(bundle))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range certs {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	for _, bundle := range roots {
+		certs, err := parseCertificates([]byte(bundle))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range certs {
+			opts.Roots.AddCert(c)
+		}
+	}
+
+	chains, err := leafCerts[0].Verify(opts)
+	if err != nil {
+		return &chainResult{Error: err.Error()}, nil
+	}
+
+	res := &chainResult{Valid: true}
+	for _, chain := range chains {
+		subjects := make([]string, len(chain))
+		for i, c := range chain {
+			subjects[i] = c.Subject.String()
+		}
+		res.Chains = append(res.Chains, subjects)
+	}
+	return res, nil
+}
+
+// pemBundles accepts either a single PEM string, which may hold several
+// certificates, or a list of them
+func pemBundles(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		bundles := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: expected a PEM string, got %T", i, item)}
+			}
+			bundles[i] = s
+		}
+		return bundles, nil
+	}
+	return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("expected a PEM string or a list of them, got %T", v)}
+}
+
+// verifyChainFunc adapts verifyChain to the arguments Sentinel passes
+func verifyChainFunc(leaf string, intermediates, roots interface{}, at time.Time) (interface{}, error) {
+	inter, err := pemBundles(intermediates)
+	if err != nil {
+		return nil, err
+	}
+	rootBundles, err := pemBundles(roots)
+	if err != nil {
+		return nil, err
+	}
+	res, err := verifyChain(leaf, inter, rootBundles, at)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "parse_cert",
+			Args:        []argSpec{{"pem", "string"}},
+			Returns:     "map",
+			Description: "Subject, issuer, SANs, serial, validity window, key type and size, signature algorithm and fingerprints of the first certificate in a PEM string. Fails with decode if there is none.",
+			Example:     `pd.parse_cert(cert_pem).not_after`,
+			Shape:       (*certInfo)(nil),
+			New: func(r *Root) interface{} {
+				return func(pem string) (interface{}, error) {
+					info, err := parseCert([]byte(pem))
+					if err != nil {
+						return nil, err
+					}
+					return info, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "parse_cert_file",
+			Args:        []argSpec{{"path", "string"}},
+			Returns:     "map",
+			Description: "parse_cert for the first certificate in a PEM file, or undefined if the file cannot be read.",
+			Example:     `pd.parse_cert_file("./tls/server.crt").dns_names`,
+			Shape:       (*certInfo)(nil),
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path string) (interface{}, error) {
+					data, err := r.readFile(path)
+					if err != nil {
+						return nil, r.fileError(err)
+					}
+					info, err := parseCert(data)
+					if err != nil {
+						var ke *kindError
+						if errors.As(err, &ke) {
+							ke.Path = path
+						}
+						return nil, err
+					}
+					return info, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "verify_chain",
+			Args:        []argSpec{{"leaf", "string"}, {"intermediates", "string or list(string)"}, {"roots", "string or list(string)"}},
+			Returns:     "map",
+			Description: "Whether the leaf certificate chains to one of the roots through the intermediates now, with the error if not and the subjects of each chain found. Certificates are PEM strings.",
+			Example:     `pd.verify_chain(leaf_pem, [intermediate_pem], root_pem).valid`,
+			Shape:       (*chainResult)(nil),
+			New: func(r *Root) interface{} {
+				return func(leaf string, intermediates, roots interface{}) (interface{}, error) {
+					return verifyChainFunc(leaf, intermediates, roots, time.Now())
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "verify_chain_at",
+			Args:        []argSpec{{"leaf", "string"}, {"intermediates", "string or list(string)"}, {"roots", "string or list(string)"}, {"time", "string"}},
+			Returns:     "map",
+			Description: "verify_chain as of an RFC 3339 time instead of now.",
+			Example:     `pd.verify_chain_at(leaf_pem, "", root_pem, "2025-01-01T00:00:00Z").valid`,
+			Shape:       (*chainResult)(nil),
+			New: func(r *Root) interface{} {
+				return func(leaf string, intermediates, roots interface{}, at string) (interface{}, error) {
+					t, err := time.Parse(time.RFC3339, at)
+					if err != nil {
+						return nil, &kindError{Kind: kindInvalidArgument, Err: err}
+					}
+					return verifyChainFunc(leaf, intermediates, roots, t)
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var certNotAfter = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// issueCert creates a certificate for cn signed by parent, or self-signed
+// if parent is nil, and returns it as PEM with its key
+func issueCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (string, *x509.Certificate, crypto.Signer) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(0x1234),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Example"}},
+		NotBefore:             time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              certNotAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if !isCA {
+		tmpl.DNSNames = []string{"app.example.com"}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), cert, key
+}
+
+func TestCertificates(t *testing.T) {
+	rootPEM, rootCert, rootKey := issueCert(t, "Root CA", true, nil, nil)
+	interPEM, interCert, interKey := issueCert(t, "Intermediate CA", true, rootCert, rootKey)
+	leafPEM, _, _ := issueCert(t, "app.example.com", false, interCert, interKey)
+	otherRootPEM, _, _ := issueCert(t, "Other CA", true, nil, nil)
+
+	// Test that certificate details are extracted
+	t.Run("ParseCert", func(t *testing.T) {
+		result, err := (&Root{}).Func("parse_cert").(func(string) (interface{}, error))(leafPEM + interPEM)
+		if err != nil {
+			t.Fatalf("parse_cert should not return error: %v", err)
+		}
+
+		info := result.(*certInfo)
+		if info.CommonName != "app.example.com" || info.Issuer != "CN=Intermediate CA,O=Example" {
+			t.Errorf("Unexpected subject or issuer %+v", info)
+		}
+		if len(info.DNSNames) != 1 || info.IPAddresses[0] != "10.0.0.1" {
+			t.Errorf("Unexpected SANs %v %v", info.DNSNames, info.IPAddresses)
+		}
+		if info.Serial != "1234" || info.NotAfter != "2030-01-01T00:00:00Z" || info.IsCA {
+			t.Errorf("Unexpected serial, validity or CA flag %+v", info)
+		}
+		if info.KeyType != "ecdsa" || info.KeySize != 256 || info.SignatureAlgorithm != "ECDSA-SHA256" {
+			t.Errorf("Unexpected key or signature algorithm %+v", info)
+		}
+		if len(info.SHA256Fingerprint) != 64 || len(info.SHA1Fingerprint) != 40 {
+			t.Errorf("Unexpected fingerprints %+v", info)
+		}
+	})
+
+	// Test that input without a certificate is a decode error
+	t.Run("ParseCertInvalid", func(t *testing.T) {
+		if _, err := parseCert([]byte("not a certificate")); errorKind(err) != kindDecode {
+			t.Errorf("Expected %s error, got %v", kindDecode, err)
+		}
+	})
+
+	// Test that certificates are read from files
+	t.Run("ParseCertFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "root.crt")
+		os.WriteFile(path, []byte(rootPEM), 0644)
+
+		parseFile := (&Root{}).Func("parse_cert_file").(func(string) (interface{}, error))
+		result, err := parseFile(path)
+		if err != nil || !result.(*certInfo).IsCA {
+			t.Errorf("Expected a CA certificate, got %+v, %v", result, err)
+		}
+		if result, err := parseFile(path + ".missing"); result != nil || err != nil {
+			t.Errorf("Expected undefined for a missing file, got %v, %v", result, err)
+		}
+	})
+
+	// Test that a chain through an intermediate verifies
+	t.Run("VerifyChain", func(t *testing.T) {
+		verify := (&Root{}).Func("verify_chain").(func(string, interface{}, interface{}) (interface{}, error))
+		result, err := verify(leafPEM, []interface{}{interPEM}, rootPEM)
+		if err != nil {
+			t.Fatalf("verify_chain should not return error: %v", err)
+		}
+
+		res := result.(*chainResult)
+		if !res.Valid || len(res.Chains) != 1 || len(res.Chains[0]) != 3 {
+			t.Errorf("Expected one chain of three, got %+v", res)
+		}
+
+		result, _ = verify(leafPEM, "", rootPEM)
+		if res := result.(*chainResult); res.Valid || res.Error == "" {
+			t.Errorf("Expected a missing intermediate to fail, got %+v", res)
+		}
+		result, _ = verify(leafPEM, interPEM, otherRootPEM)
+		if res := result.(*chainResult); res.Valid {
+			t.Errorf("Expected an unknown root to fail, got %+v", res)
+		}
+		if _, err := verify(leafPEM, interPEM, ""); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error without roots, got %v", kindInvalidArgument, err)
+		}
+	})
+
+	// Test that the evaluation time is honored
+	t.Run("VerifyChainAt", func(t *testing.T) {
+		verifyAt := (&Root{}).Func("verify_chain_at").(func(string, interface{}, interface{}, string) (interface{}, error))
+		result, _ := verifyAt(leafPEM, interPEM, rootPEM, "2031-01-01T00:00:00Z")
+		if res := result.(*chainResult); res.Valid {
+			t.Errorf("Expected an expired chain to fail, got %+v", res)
+		}
+		result, _ = verifyAt(leafPEM, interPEM, rootPEM, "2025-06-01T00:00:00Z")
+		if res := result.(*chainResult); !res.Valid {
+			t.Errorf("Expected the chain to be valid in 2025, got %+v", res)
+		}
+		if _, err := verifyAt(leafPEM, interPEM, rootPEM, "tomorrow"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package plugin
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// parsePrefix parses a CIDR block such as "10.0.0.0/16" or "fd00::/8".
+// Host bits are cleared, as Terraform does, so "10.0.0.1/16" is
+// 10.0.0.0/16. A plain address is taken as a single-address block when
+// allowAddr is set.
+func parsePrefix(s string, allowAddr bool) (netip.Prefix, error) {
+	if allowAddr && !strings.Contains(s, "/") {
+		addr, err := parseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("invalid CIDR %q", s)}
+	}
+	return p.Masked(), nil
+}
+
+func parseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("invalid IP address %q", s)}
+	}
+	return addr, nil
+}
+
+// prefixRange returns the first and last address of p as integers
+func prefixRange(p netip.Prefix) (*big.Int, *big.Int) {
+	first := new(big.Int).SetBytes(p.Addr().AsSlice())
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	last := new(big.Int).Add(first, size)
+	return first, last.Sub(last, big.NewInt(1))
+}
+
+// addrFromInt converts n back to an address of the given family
+func addrFromInt(n *big.Int, bits int) netip.Addr {
+	b := make([]byte, bits/8)
+	n.FillBytes(b)
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// cidrContains reports whether inner, an address or a CIDR block, lies
+// entirely within outer
+func cidrContains(outer, inner string) (bool, error) {
+	o, err := parsePrefix(outer, false)
+	if err != nil {
+		return false, err
+	}
+	i, err := parsePrefix(inner, true)
+	if err != nil {
+		return false, err
+	}
+	return o.Bits() <= i.Bits() && o.Contains(i.Addr()), nil
+}
+
+// cidrSubnets allocates consecutive subnets of base, each newbits longer
+// than base, aligned on their own size. This is Terraform's cidrsubnets.
+func cidrSubnets(base string, newbits []interface{}) ([]string, error) {
+	p, err := parsePrefix(base, false)
+	if err != nil {
+		return nil, err
+	}
+	bits := p.Addr().BitLen()
+	next, end := prefixRange(p)
+	end.Add(end, big.NewInt(1))
+
+	subnets := make([]string, 0, len(newbits))
+	for i, v := range newbits {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: %s", i, err)}
+		}
+		length := p.Bits() + int(n)
+		if n < 1 || length > bits {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: %d new bits do not fit in %s", i, n, p)}
+		}
+
+		// Round up to the next block of this size
+		size := new(big.Int).Lsh(big.NewInt(1), uint(bits-length))
+		start := new(big.Int).Add(next, size)
+		start.Sub(start, big.NewInt(1))
+		start.Div(start, size).Mul(start, size)
+		next = new(big.Int).Add(start, size)
+		if next.Cmp(end) > 0 {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: not enough address space left in %s for a /%d", i, p, length)}
+		}
+		subnets = append(subnets, netip.PrefixFrom(addrFromInt(start, bits), length).String())
+	}
+	return subnets, nil
+}
+
+// cidrHost returns the address at hostnum in the block, counting back
+// from the end when hostnum is negative, as Terraform's cidrhost does
+func cidrHost(cidr string, hostnum int64) (string, error) {
+	p, err := parsePrefix(cidr, false)
+	if err != nil {
+		return "", err
+	}
+	first, last := prefixRange(p)
+
+	addr := new(big.Int)
+	if hostnum >= 0 {
+		addr.Add(first, big.NewInt(hostnum))
+	} else {
+		addr.Add(last, big.NewInt(hostnum+1))
+	}
+	if addr.Cmp(first) < 0 || addr.Cmp(last) > 0 {
+		return "", &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("host number %d is outside %s", hostnum, p)}
+	}
+	return addrFromInt(addr, p.Addr().BitLen()).String(), nil
+}
+
+// ipIsPrivate reports whether an address, or every address of a CIDR
+// block, is in a private range: RFC 1918 for IPv4 and RFC 4193 unique
+// local addresses for IPv6
+func ipIsPrivate(s string) (bool, error) {
+	p, err := parsePrefix(s, true)
+	if err != nil {
+		return false, err
+	}
+	first, last := prefixRange(p)
+	bits := p.Addr().BitLen()
+	// The private ranges are themselves CIDR blocks, so a block starting
+	// and ending in one lies entirely within it
+	return addrFromInt(first, bits).IsPrivate() && addrFromInt(last, bits).IsPrivate(), nil
+}
+
+// cidrMerge aggregates blocks and addresses into the fewest CIDR blocks
+// covering exactly the same addresses, IPv4 first, each family sorted
+func cidrMerge(list []interface{}) ([]string, error) {
+	type span struct {
+		bits        int
+		first, last *big.Int
+	}
+	var spans []span
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: expected a CIDR string, got %T", i, item)}
+		}
+		p, err := parsePrefix(s, true)
+		if err != nil {
+			return nil, err
+		}
+		first, last := prefixRange(p)
+		spans = append(spans, span{p.Addr().BitLen(), first, last})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].bits != spans[j].bits {
+			return spans[i].bits < spans[j].bits
+		}
+		return spans[i].first.Cmp(spans[j].first) < 0
+	})
+
+	// Join overlapping and adjacent ranges, then split each back into
+	// the largest aligned blocks
+	one := big.NewInt(1)
+	merged := []span{}
+	for _, s := range spans {
+		if n := len(merged); n > 0 && merged[n-1].bits == s.bits &&
+			new(big.Int).Add(merged[n-1].last, one).Cmp(s.first) >= 0 {
+			if s.last.Cmp(merged[n-1].last) > 0 {
+				merged[n-1].last = s.last
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	result := []string{}
+	for _, s := range merged {
+		start := new(big.Int).Set(s.first)
+		for start.Cmp(s.last) <= 0 {
+			// The largest block aligned at start that does not run past
+			// the end of the range
+			host := s.bits
+			if start.Sign() > 0 && int(start.TrailingZeroBits()) < host {
+				host = int(start.TrailingZeroBits())
+			}
+			for {
+				end := new(big.Int).Lsh(one, uint(host))
+				end.Add(end, start).Sub(end, one)
+				if end.Cmp(s.last) <= 0 {
+					break
+				}
+				host--
+			}
+			result = append(result, netip.PrefixFrom(addrFromInt(start, s.bits), s.bits-host).String())
+			start.Add(start, new(big.Int).Lsh(one, uint(host)))
+		}
+	}
+	return result, nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "cidr_contains",
+			Args:        []argSpec{{"cidr", "string"}, {"ip_or_cidr", "string"}},
+			Returns:     "bool",
+			Description: "Whether an IP address, or every address of a CIDR block, is within a CIDR block. Addresses of the other IP version are never contained.",
+			Example:     `pd.cidr_contains("10.0.0.0/8", "10.1.2.0/24")`,
+			New: func(r *Root) interface{} {
+				return func(cidr, inner string) (interface{}, error) {
+					ok, err := cidrContains(cidr, inner)
+					if err != nil {
+						return nil, err
+					}
+					return ok, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "cidr_overlaps",
+			Args:        []argSpec{{"a", "string"}, {"b", "string"}},
+			Returns:     "bool",
+			Description: "Whether two CIDR blocks share any address.",
+			Example:     `not pd.cidr_overlaps("10.0.0.0/16", "10.1.0.0/16")`,
+			New: func(r *Root) interface{} {
+				return func(a, b string) (interface{}, error) {
+					pa, err := parsePrefix(a, false)
+					if err != nil {
+						return nil, err
+					}
+					pb, err := parsePrefix(b, false)
+					if err != nil {
+						return nil, err
+					}
+					return pa.Overlaps(pb), nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "cidr_subnets",
+			Args:        []argSpec{{"cidr", "string"}, {"newbits", "list(int)"}},
+			Returns:     "list(string)",
+			Description: "Consecutive subnets of a CIDR block, each with the given number of additional prefix bits, as Terraform's cidrsubnets allocates them. Fails with invalid_argument if they do not fit.",
+			Example:     `pd.cidr_subnets("10.0.0.0/16", [8, 8, 4])`,
+			New: func(r *Root) interface{} {
+				return func(cidr string, newbits []interface{}) (interface{}, error) {
+					subnets, err := cidrSubnets(cidr, newbits)
+					if err != nil {
+						return nil, err
+					}
+					return subnets, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "cidr_host",
+			Args:        []argSpec{{"cidr", "string"}, {"hostnum", "int"}},
+			Returns:     "string",
+			Description: "The address at a host number within a CIDR block, counting back from the last address when negative, as Terraform's cidrhost does.",
+			Example:     `pd.cidr_host("10.0.1.0/24", 5)`,
+			New: func(r *Root) interface{} {
+				return func(cidr string, hostnum int64) (interface{}, error) {
+					addr, err := cidrHost(cidr, hostnum)
+					if err != nil {
+						return nil, err
+					}
+					return addr, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "ip_is_private",
+			Args:        []argSpec{{"ip_or_cidr", "string"}},
+			Returns:     "bool",
+			Description: "Whether an IP address, or every address of a CIDR block, is private: 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16 or fc00::/7.",
+			Example:     `pd.ip_is_private("0.0.0.0/0") is false`,
+			New: func(r *Root) interface{} {
+				return func(s string) (interface{}, error) {
+					ok, err := ipIsPrivate(s)
+					if err != nil {
+						return nil, err
+					}
+					return ok, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "ip_version",
+			Args:        []argSpec{{"ip_or_cidr", "string"}},
+			Returns:     "int",
+			Description: "4 or 6, the version of an IP address or CIDR block.",
+			Example:     `pd.ip_version("2001:db8::/32") is 6`,
+			New: func(r *Root) interface{} {
+				return func(s string) (interface{}, error) {
+					p, err := parsePrefix(s, true)
+					if err != nil {
+						return nil, err
+					}
+					if p.Addr().Is4() {
+						return 4, nil
+					}
+					return 6, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "cidr_merge",
+			Args:        []argSpec{{"cidrs", "list(string)"}},
+			Returns:     "list(string)",
+			Description: "The fewest CIDR blocks covering exactly the addresses of a list of blocks and addresses, with overlapping and adjacent blocks combined. IPv4 blocks come first.",
+			Example:     `pd.cidr_merge(["10.0.0.0/24", "10.0.1.0/24"])`,
+			New: func(r *Root) interface{} {
+				return func(list []interface{}) (interface{}, error) {
+					merged, err := cidrMerge(list)
+					if err != nil {
+						return nil, err
+					}
+					return merged, nil
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,173 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCIDR(t *testing.T) {
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+
+	// Test that contains accepts addresses and blocks of either version
+	t.Run("Contains", func(t *testing.T) {
+		contains := root.Func("cidr_contains").(func(string, string) (interface{}, error))
+		for _, c := range []struct {
+			outer, inner string
+			want         bool
+		}{
+			{"10.0.0.0/8", "10.1.2.3", true},
+			{"10.0.0.0/8", "10.1.2.0/24", true},
+			{"10.0.0.0/16", "10.0.0.0/8", false},
+			{"10.0.0.0/8", "11.0.0.1", false},
+			{"10.0.0.1/8", "10.255.255.255", true},
+			{"2001:db8::/32", "2001:db8:1::/48", true},
+			{"0.0.0.0/0", "::1", false},
+		} {
+			got, err := contains(c.outer, c.inner)
+			if err != nil || got != c.want {
+				t.Errorf("contains(%s, %s): expected %v, got %v, %v", c.outer, c.inner, c.want, got, err)
+			}
+		}
+
+		for _, args := range [][2]string{{"10.0.0.0", "10.0.0.1"}, {"10.0.0.0/33", "10.0.0.1"}, {"10.0.0.0/8", "10.0.0.256"}, {"fe80::/10", "fe80::1%eth0"}} {
+			if _, err := contains(args[0], args[1]); errorKind(err) != kindInvalidArgument {
+				t.Errorf("contains(%s, %s): expected invalid_argument, got %v", args[0], args[1], err)
+			}
+		}
+	})
+
+	// Test that overlapping and disjoint blocks are told apart
+	t.Run("Overlaps", func(t *testing.T) {
+		overlaps := root.Func("cidr_overlaps").(func(string, string) (interface{}, error))
+		for _, c := range []struct {
+			a, b string
+			want bool
+		}{
+			{"10.0.0.0/16", "10.0.128.0/17", true},
+			{"10.0.0.0/16", "10.1.0.0/16", false},
+			{"0.0.0.0/0", "192.168.1.0/24", true},
+			{"fd00::/8", "fd12::/16", true},
+			{"10.0.0.0/8", "::/0", false},
+		} {
+			got, err := overlaps(c.a, c.b)
+			if err != nil || got != c.want {
+				t.Errorf("overlaps(%s, %s): expected %v, got %v, %v", c.a, c.b, c.want, got, err)
+			}
+		}
+	})
+
+	// Test that subnets are allocated the way Terraform's cidrsubnets does
+	t.Run("Subnets", func(t *testing.T) {
+		subnets := root.Func("cidr_subnets").(func(string, []interface{}) (interface{}, error))
+		for _, c := range []struct {
+			base    string
+			newbits []interface{}
+			want    []string
+		}{
+			{"10.1.0.0/16", []interface{}{int64(4), int64(4), int64(8), int64(4)}, []string{"10.1.0.0/20", "10.1.16.0/20", "10.1.32.0/24", "10.1.48.0/20"}},
+			{"10.0.0.0/8", []interface{}{int64(8), int64(4)}, []string{"10.0.0.0/16", "10.16.0.0/12"}},
+			{"fd00:fd12:3456:7890::/56", []interface{}{int64(16), int64(16)}, []string{"fd00:fd12:3456:7800::/72", "fd00:fd12:3456:7800:100::/72"}},
+		} {
+			got, err := subnets(c.base, c.newbits)
+			if err != nil || !reflect.DeepEqual(got, c.want) {
+				t.Errorf("subnets(%s, %v): expected %v, got %v, %v", c.base, c.newbits, c.want, got, err)
+			}
+		}
+
+		for _, newbits := range [][]interface{}{{int64(1), int64(1), int64(1)}, {int64(9)}, {int64(0)}, {"8"}} {
+			if _, err := subnets("10.0.0.0/24", newbits); errorKind(err) != kindInvalidArgument {
+				t.Errorf("subnets(%v): expected invalid_argument, got %v", newbits, err)
+			}
+		}
+	})
+
+	// Test that host numbers count from either end of the block
+	t.Run("Host", func(t *testing.T) {
+		host := root.Func("cidr_host").(func(string, int64) (interface{}, error))
+		for _, c := range []struct {
+			cidr    string
+			hostnum int64
+			want    string
+		}{
+			{"10.12.112.0/20", 16, "10.12.112.16"},
+			{"10.12.112.0/20", 268, "10.12.113.12"},
+			{"10.12.112.0/20", -1, "10.12.127.255"},
+			{"fd00:fd12:3456:7890:00a2::/72", 34, "fd00:fd12:3456:7890::22"},
+		} {
+			got, err := host(c.cidr, c.hostnum)
+			if err != nil || got != c.want {
+				t.Errorf("host(%s, %d): expected %s, got %v, %v", c.cidr, c.hostnum, c.want, got, err)
+			}
+		}
+
+		for _, n := range []int64{256, -257} {
+			if _, err := host("10.0.0.0/24", n); errorKind(err) != kindInvalidArgument {
+				t.Errorf("host(%d): expected invalid_argument, got %v", n, err)
+			}
+		}
+	})
+
+	// Test that private ranges are recognized for addresses and blocks
+	t.Run("Private", func(t *testing.T) {
+		private := root.Func("ip_is_private").(func(string) (interface{}, error))
+		for s, want := range map[string]bool{
+			"10.1.2.3":       true,
+			"172.16.0.0/12":  true,
+			"172.16.0.0/11":  false,
+			"192.168.1.0/24": true,
+			"0.0.0.0/0":      false,
+			"8.8.8.8":        false,
+			"fd12::1":        true,
+			"fc00::/7":       true,
+			"::/0":           false,
+			"127.0.0.1":      false,
+		} {
+			got, err := private(s)
+			if err != nil || got != want {
+				t.Errorf("private(%s): expected %v, got %v, %v", s, want, got, err)
+			}
+		}
+	})
+
+	// Test that the version is reported for addresses and blocks
+	t.Run("Version", func(t *testing.T) {
+		version := root.Func("ip_version").(func(string) (interface{}, error))
+		for s, want := range map[string]int{"10.0.0.1": 4, "10.0.0.0/8": 4, "::1": 6, "2001:db8::/32": 6} {
+			if got, err := version(s); err != nil || got != want {
+				t.Errorf("version(%s): expected %d, got %v, %v", s, want, got, err)
+			}
+		}
+		if _, err := version("localhost"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument, got %v", err)
+		}
+	})
+
+	// Test that merge combines overlapping and adjacent blocks
+	t.Run("Merge", func(t *testing.T) {
+		merge := root.Func("cidr_merge").(func([]interface{}) (interface{}, error))
+		got, err := merge([]interface{}{
+			"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/23", "10.0.3.7",
+			"192.168.0.0/24", "192.168.2.0/24",
+			"2001:db8::/33", "2001:db8:8000::/33",
+			"10.0.4.0/24",
+		})
+		want := []string{"10.0.0.0/22", "10.0.4.0/24", "192.168.0.0/24", "192.168.2.0/24", "2001:db8::/32"}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v, %v", want, got, err)
+		}
+
+		got, err = merge([]interface{}{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"})
+		want = []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/32"}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v, %v", want, got, err)
+		}
+
+		if got, err := merge([]interface{}{"0.0.0.0/1", "128.0.0.0/1"}); err != nil || !reflect.DeepEqual(got, []string{"0.0.0.0/0"}) {
+			t.Errorf("Expected [0.0.0.0/0], got %v, %v", got, err)
+		}
+		if _, err := merge([]interface{}{"10.0.0.0/8", "bogus"}); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument, got %v", err)
+		}
+	})
+}
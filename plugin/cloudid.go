@@ -0,0 +1,274 @@
+package plugin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Return structs
+type arnInfo struct {
+	Partition    string // aws, aws-cn, aws-us-gov...
+	Service      string
+	Region       string // empty for global services such as IAM and S3
+	Account      string // empty for S3 buckets
+	Resource     string // everything after the account
+	ResourceType string // e.g. role in role/admin, empty if there is none or for S3 buckets
+	ResourceID   string `sentinel:"resource_id"` // e.g. admin in role/admin
+}
+
+type azureID struct {
+	Subscription  string
+	ResourceGroup string
+	Provider      string // namespace of the last provider, e.g. Microsoft.Network
+	ResourceType  string // e.g. Microsoft.Network/virtualNetworks/subnets
+	Name          string // name of the last resource
+	Resources     []*cloudResource
+}
+
+type gcpName struct {
+	Service      string // e.g. compute.googleapis.com, for full names and self links
+	Organization string
+	Folder       string
+	Project      string
+	Location     string // from locations, regions or zones
+	ResourceType string // the last collection, e.g. instances
+	Name         string // the last ID
+	Resources    []*cloudResource
+}
+
+// cloudResource is one collection and ID pair of a resource path
+type cloudResource struct {
+	Type string
+	Name string
+}
+
+func invalidID(kind, s, reason string) error {
+	return &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("invalid %s %q: %s", kind, s, reason)}
+}
+
+// parseARN splits an Amazon Resource Name,
+// arn:partition:service:region:account:resource, where the resource is
+// an ID on its own or prefixed by its type and a slash or colon. S3
+// buckets and objects, which have no account, are the exception: the
+// whole of bucket/key is the ID.
+func parseARN(s string) (*arnInfo, error) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return nil, invalidID("ARN", s, "expected arn:partition:service:region:account:resource")
+	}
+	if parts[1] == "" || parts[2] == "" || parts[5] == "" {
+		return nil, invalidID("ARN", s, "partition, service and resource are required")
+	}
+
+	a := &arnInfo{
+		Partition: parts[1],
+		Service:   parts[2],
+		Region:    parts[3],
+		Account:   parts[4],
+		Resource:  parts[5],
+	}
+	a.ResourceID = a.Resource
+	if a.Service == "s3" && a.Account == "" {
+		return a, nil
+	}
+	if i := strings.IndexAny(a.Resource, "/:"); i > 0 {
+		a.ResourceType, a.ResourceID = a.Resource[:i], a.Resource[i+1:]
+	}
+	return a, nil
+}
+
+// parseAzureID splits an Azure resource ID such as
+// /subscriptions/<id>/resourceGroups/<rg>/providers/Microsoft.Network/virtualNetworks/<vnet>/subnets/<subnet>.
+// Segment names are case-insensitive, as they are in Azure. For extension
+// resources, which have a second providers segment, the provider and
+// resource type are those of the last one.
+func parseAzureID(s string) (*azureID, error) {
+	if !strings.HasPrefix(s, "/") {
+		return nil, invalidID("Azure resource ID", s, "must start with /")
+	}
+	segs := strings.Split(strings.Trim(s, "/"), "/")
+	id := &azureID{Resources: []*cloudResource{}}
+
+	var types []string
+	for i := 0; i < len(segs); {
+		key := segs[i]
+		if i+1 >= len(segs) || key == "" || segs[i+1] == "" {
+			return nil, invalidID("Azure resource ID", s, fmt.Sprintf("%q has no value", key))
+		}
+		value := segs[i+1]
+		i += 2
+
+		switch {
+		case strings.EqualFold(key, "subscriptions") && id.Provider == "":
+			id.Subscription = value
+		case strings.EqualFold(key, "resourceGroups") && id.Provider == "":
+			id.ResourceGroup = value
+		case strings.EqualFold(key, "providers"):
+			// The namespace is followed by a type and name
+			if i+1 >= len(segs) {
+				return nil, invalidID("Azure resource ID", s, fmt.Sprintf("provider %q has no resource", value))
+			}
+			id.Provider = value
+			types = nil
+			key, value = segs[i], segs[i+1]
+			i += 2
+			fallthrough
+		default:
+			if id.Provider == "" {
+				return nil, invalidID("Azure resource ID", s, fmt.Sprintf("unexpected %q before providers", key))
+			}
+			types = append(types, key)
+			id.Resources = append(id.Resources, &cloudResource{Type: key, Name: value})
+			id.Name = value
+		}
+	}
+	if id.Subscription == "" && id.Provider == "" {
+		return nil, invalidID("Azure resource ID", s, "expected subscriptions or providers")
+	}
+	if id.Provider != "" {
+		id.ResourceType = id.Provider + "/" + strings.Join(types, "/")
+	}
+	return id, nil
+}
+
+// gcpLocationTypes are the collections whose ID is a location
+var gcpLocationTypes = map[string]bool{"locations": true, "regions": true, "zones": true}
+
+// parseGCPName splits a Google Cloud resource name. It accepts relative
+// names (projects/p/zones/z/instances/i), full names
+// (//compute.googleapis.com/projects/...) and self links
+// (https://www.googleapis.com/compute/v1/projects/...). A global segment,
+// as in projects/p/global/networks/n, sets the location to "global".
+func parseGCPName(s string) (*gcpName, error) {
+	n := &gcpName{Resources: []*cloudResource{}}
+	path := s
+	switch {
+	case strings.HasPrefix(s, "//"):
+		service, rest, _ := strings.Cut(s[2:], "/")
+		n.Service, path = service, rest
+	case strings.HasPrefix(s, "https://"):
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, invalidID("GCP resource name", s, err.Error())
+		}
+		n.Service = u.Host
+		path = strings.TrimPrefix(u.Path, "/")
+		// Self links name the service and API version before the
+		// resource path: www.googleapis.com/compute/v1/projects/...
+		if i := gcpRootIndex(path); i > 0 {
+			prefix := strings.Split(path[:i-1], "/")
+			if n.Service == "www.googleapis.com" {
+				n.Service = prefix[0] + ".googleapis.com"
+			}
+			path = path[i:]
+		}
+	}
+
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if gcpRootIndex(path) != 0 {
+		return nil, invalidID("GCP resource name", s, "expected collection/ID pairs starting with projects, folders or organizations")
+	}
+	for i := 0; i < len(segs); i += 2 {
+		// Compute names global resources projects/p/global/networks/n,
+		// with a location segment that has no ID
+		if segs[i] == "global" && i > 0 {
+			if i+1 == len(segs) {
+				return nil, invalidID("GCP resource name", s, "nothing follows global")
+			}
+			if n.Location == "" {
+				n.Location = "global"
+			}
+			i--
+			continue
+		}
+		if i+1 >= len(segs) {
+			return nil, invalidID("GCP resource name", s, fmt.Sprintf("%q has no ID", segs[i]))
+		}
+		typ, name := segs[i], segs[i+1]
+		if typ == "" || name == "" {
+			return nil, invalidID("GCP resource name", s, "empty collection or ID")
+		}
+		switch {
+		case typ == "projects" && n.Project == "":
+			n.Project = name
+		case typ == "organizations" && n.Organization == "":
+			n.Organization = name
+		case typ == "folders" && n.Folder == "":
+			n.Folder = name
+		case gcpLocationTypes[typ] && n.Location == "":
+			n.Location = name
+		}
+		n.Resources = append(n.Resources, &cloudResource{Type: typ, Name: name})
+		n.ResourceType, n.Name = typ, name
+	}
+	return n, nil
+}
+
+// gcpRootIndex returns where the resource path starts in path, or -1
+func gcpRootIndex(path string) int {
+	best := -1
+	for _, root := range []string{"projects/", "organizations/", "folders/", "billingAccounts/"} {
+		i := strings.Index("/"+path, "/"+root)
+		if i >= 0 && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	return best
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "parse_arn",
+			Args:        []argSpec{{"arn", "string"}},
+			Returns:     "map",
+			Description: "Partition, service, region, account and resource of an AWS ARN, with the resource split into its type and ID, except for S3 buckets and objects, whose whole bucket/key is the ID. Fails with invalid_argument if it is not an ARN.",
+			Example:     `pd.parse_arn("arn:aws:iam::123456789012:role/admin").account is "123456789012"`,
+			Shape:       (*arnInfo)(nil),
+			New: func(r *Root) interface{} {
+				return func(s string) (interface{}, error) {
+					a, err := parseARN(s)
+					if err != nil {
+						return nil, err
+					}
+					return a, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "parse_azure_id",
+			Args:        []argSpec{{"id", "string"}},
+			Returns:     "map",
+			Description: "Subscription, resource group, provider namespace, full resource type and name of an Azure resource ID, with each type and name pair below the provider. Fails with invalid_argument if it is not a resource ID.",
+			Example:     `pd.parse_azure_id(rc.change.after.id).resource_group`,
+			Shape:       (*azureID)(nil),
+			New: func(r *Root) interface{} {
+				return func(s string) (interface{}, error) {
+					id, err := parseAzureID(s)
+					if err != nil {
+						return nil, err
+					}
+					return id, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "parse_gcp_name",
+			Args:        []argSpec{{"name", "string"}},
+			Returns:     "map",
+			Description: "Service, organization, folder, project, location, resource type and name of a Google Cloud resource name, full resource name or self link, with each collection and ID pair. Fails with invalid_argument if it is not a resource name.",
+			Example:     `pd.parse_gcp_name(rc.change.after.self_link).project`,
+			Shape:       (*gcpName)(nil),
+			New: func(r *Root) interface{} {
+				return func(s string) (interface{}, error) {
+					n, err := parseGCPName(s)
+					if err != nil {
+						return nil, err
+					}
+					return n, nil
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloudIDs(t *testing.T) {
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+
+	// Test that ARNs are split, with resource types after / or :, except
+	// for S3 buckets and objects
+	t.Run("ARN", func(t *testing.T) {
+		parse := root.Func("parse_arn").(func(string) (interface{}, error))
+		for s, want := range map[string]*arnInfo{
+			"arn:aws:iam::123456789012:role/service-role/admin": {
+				Partition: "aws", Service: "iam", Account: "123456789012",
+				Resource: "role/service-role/admin", ResourceType: "role", ResourceID: "service-role/admin",
+			},
+			"arn:aws-us-gov:lambda:us-gov-west-1:123456789012:function:handler:live": {
+				Partition: "aws-us-gov", Service: "lambda", Region: "us-gov-west-1", Account: "123456789012",
+				Resource: "function:handler:live", ResourceType: "function", ResourceID: "handler:live",
+			},
+			"arn:aws:s3:::my-bucket": {
+				Partition: "aws", Service: "s3", Resource: "my-bucket", ResourceID: "my-bucket",
+			},
+			"arn:aws:s3:::my-bucket/logs/2024/app.log": {
+				Partition: "aws", Service: "s3", Resource: "my-bucket/logs/2024/app.log", ResourceID: "my-bucket/logs/2024/app.log",
+			},
+			"arn:aws:s3:us-west-2:123456789012:accesspoint/reports": {
+				Partition: "aws", Service: "s3", Region: "us-west-2", Account: "123456789012",
+				Resource: "accesspoint/reports", ResourceType: "accesspoint", ResourceID: "reports",
+			},
+			"arn:aws:logs:eu-west-1:123456789012:log-group:/aws/lambda/x:*": {
+				Partition: "aws", Service: "logs", Region: "eu-west-1", Account: "123456789012",
+				Resource: "log-group:/aws/lambda/x:*", ResourceType: "log-group", ResourceID: "/aws/lambda/x:*",
+			},
+		} {
+			got, err := parse(s)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %+v, got %+v, %v", s, want, got, err)
+			}
+		}
+
+		for _, s := range []string{"", "arn:aws:s3", "urn:aws:s3:::bucket", "arn::s3:::bucket", "arn:aws:s3:::"} {
+			if _, err := parse(s); errorKind(err) != kindInvalidArgument {
+				t.Errorf("%q: expected invalid_argument, got %v", s, err)
+			}
+		}
+	})
+
+	// Test that Azure IDs are split into subscription, group and resources
+	t.Run("Azure", func(t *testing.T) {
+		parse := root.Func("parse_azure_id").(func(string) (interface{}, error))
+		for s, want := range map[string]*azureID{
+			"/subscriptions/0000-1111/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/default": {
+				Subscription: "0000-1111", ResourceGroup: "network-rg", Provider: "Microsoft.Network",
+				ResourceType: "Microsoft.Network/virtualNetworks/subnets", Name: "default",
+				Resources: []*cloudResource{{"virtualNetworks", "vnet"}, {"subnets", "default"}},
+			},
+			"/subscriptions/0000-1111/resourcegroups/rg": {
+				Subscription: "0000-1111", ResourceGroup: "rg", Resources: []*cloudResource{},
+			},
+			"/subscriptions/0000-1111/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa/providers/Microsoft.Authorization/roleAssignments/ra": {
+				Subscription: "0000-1111", ResourceGroup: "rg", Provider: "Microsoft.Authorization",
+				ResourceType: "Microsoft.Authorization/roleAssignments", Name: "ra",
+				Resources: []*cloudResource{{"storageAccounts", "sa"}, {"roleAssignments", "ra"}},
+			},
+			"/providers/Microsoft.Management/managementGroups/root": {
+				Provider: "Microsoft.Management", ResourceType: "Microsoft.Management/managementGroups", Name: "root",
+				Resources: []*cloudResource{{"managementGroups", "root"}},
+			},
+		} {
+			got, err := parse(s)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %+v, got %+v, %v", s, want, got, err)
+			}
+		}
+
+		for _, s := range []string{"", "subscriptions/x", "/subscriptions", "/subscriptions/x/resourceGroups/rg/virtualNetworks/v", "/subscriptions/x/providers/Microsoft.Network", "/subscriptions/x/providers/Microsoft.Network/virtualNetworks"} {
+			if _, err := parse(s); errorKind(err) != kindInvalidArgument {
+				t.Errorf("%q: expected invalid_argument, got %v", s, err)
+			}
+		}
+	})
+
+	// Test that GCP relative names, full names and self links are split
+	t.Run("GCP", func(t *testing.T) {
+		parse := root.Func("parse_gcp_name").(func(string) (interface{}, error))
+		instance := []*cloudResource{{"projects", "my-project"}, {"zones", "us-central1-a"}, {"instances", "vm-1"}}
+		for s, want := range map[string]*gcpName{
+			"projects/my-project/zones/us-central1-a/instances/vm-1": {
+				Project: "my-project", Location: "us-central1-a", ResourceType: "instances", Name: "vm-1", Resources: instance,
+			},
+			"//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm-1": {
+				Service: "compute.googleapis.com", Project: "my-project", Location: "us-central1-a", ResourceType: "instances", Name: "vm-1", Resources: instance,
+			},
+			"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/vm-1": {
+				Service: "compute.googleapis.com", Project: "my-project", Location: "us-central1-a", ResourceType: "instances", Name: "vm-1", Resources: instance,
+			},
+			"projects/p/locations/europe-west1/keyRings/kr/cryptoKeys/ck": {
+				Project: "p", Location: "europe-west1", ResourceType: "cryptoKeys", Name: "ck",
+				Resources: []*cloudResource{{"projects", "p"}, {"locations", "europe-west1"}, {"keyRings", "kr"}, {"cryptoKeys", "ck"}},
+			},
+			"projects/my-project/global/networks/default": {
+				Project: "my-project", Location: "global", ResourceType: "networks", Name: "default",
+				Resources: []*cloudResource{{"projects", "my-project"}, {"networks", "default"}},
+			},
+			"https://www.googleapis.com/compute/v1/projects/my-project/global/firewalls/allow-ssh": {
+				Service: "compute.googleapis.com", Project: "my-project", Location: "global", ResourceType: "firewalls", Name: "allow-ssh",
+				Resources: []*cloudResource{{"projects", "my-project"}, {"firewalls", "allow-ssh"}},
+			},
+			"https://www.googleapis.com/compute/v1/projects/my-project/global/addresses/lb-ip": {
+				Service: "compute.googleapis.com", Project: "my-project", Location: "global", ResourceType: "addresses", Name: "lb-ip",
+				Resources: []*cloudResource{{"projects", "my-project"}, {"addresses", "lb-ip"}},
+			},
+			"organizations/123/folders/456": {
+				Organization: "123", Folder: "456", ResourceType: "folders", Name: "456",
+				Resources: []*cloudResource{{"organizations", "123"}, {"folders", "456"}},
+			},
+		} {
+			got, err := parse(s)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %+v, got %+v, %v", s, want, got, err)
+			}
+		}
+
+		for _, s := range []string{"", "projects", "projects/p/zones", "buckets/b", "//storage.googleapis.com/b/o", "projects//zones/z", "projects/p/global", "projects/p/global/networks"} {
+			if _, err := parse(s); errorKind(err) != kindInvalidArgument {
+				t.Errorf("%q: expected invalid_argument, got %v", s, err)
+			}
+		}
+	})
+}
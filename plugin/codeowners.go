@@ -0,0 +1,335 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Where GitHub and GitLab look for CODEOWNERS, in the order they do;
+// the first one found is used
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+var (
+	// GitLab section headers: an optional ^, the name in brackets, an
+	// optional approval count in brackets, then default owners
+	codeownersSectionRe = regexp.MustCompile(`^(\^)?\[([^\]]+)\](?:\[(\d+)\])?\s*(.*)$`)
+	codeownersOwnerRe   = regexp.MustCompile(`^(@[\w.-]+(/[\w./-]+)?|[^@\s]+@[^@\s]+\.[^@\s]+)$`)
+)
+
+// Return structs
+type codeownersFile struct {
+	Path     string
+	Rules    []*codeownersRule
+	Sections []*codeownersSection
+	Errors   []*codeownersError // lines that were skipped
+}
+
+type codeownersRule struct {
+	Pattern string
+	Owners  []string
+	Section string // empty outside GitLab sections
+	Line    int
+
+	re *regexp.Regexp
+}
+
+type codeownersSection struct {
+	Name          string
+	Optional      bool
+	Approvals     int
+	DefaultOwners []string
+}
+
+type codeownersError struct {
+	Line  int
+	Error string
+}
+
+type fileOwnership struct {
+	File       string
+	Codeowners string            // the CODEOWNERS file used
+	Owners     []string          // from every section, without duplicates
+	Rules      []*codeownersRule // the rule that won in each section
+}
+
+// parseCodeowners parses a GitHub or GitLab CODEOWNERS file. Lines that
+// cannot be used, such as patterns with ! or owners that are neither
+// @users, @org/teams nor emails, are reported in Errors, as GitHub does,
+// rather than failing the whole file.
+func parseCodeowners(file string, data []byte) *codeownersFile {
+	co := &codeownersFile{Path: file, Rules: []*codeownersRule{}, Sections: []*codeownersSection{}, Errors: []*codeownersError{}}
+	sections := map[string]*codeownersSection{}
+	var section *codeownersSection
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fail := func(format string, args ...interface{}) {
+			co.Errors = append(co.Errors, &codeownersError{Line: n, Error: fmt.Sprintf(format, args...)})
+		}
+
+		if m := codeownersSectionRe.FindStringSubmatch(line); m != nil {
+			owners := strings.Fields(m[4])
+			if bad := invalidOwners(owners); len(bad) > 0 {
+				fail("invalid owners %s", strings.Join(bad, ", "))
+				continue
+			}
+			// Sections with the same name, in any case, are one section
+			key := strings.ToLower(m[2])
+			if section = sections[key]; section == nil {
+				section = &codeownersSection{Name: m[2], DefaultOwners: []string{}}
+				sections[key] = section
+				co.Sections = append(co.Sections, section)
+			}
+			section.Optional = m[1] != ""
+			if m[3] != "" {
+				section.Approvals, _ = strconv.Atoi(m[3])
+			}
+			if len(owners) > 0 {
+				section.DefaultOwners = owners
+			}
+			continue
+		}
+
+		pattern, owners := splitCodeownersLine(line)
+		if bad := invalidOwners(owners); len(bad) > 0 {
+			fail("invalid owners %s", strings.Join(bad, ", "))
+			continue
+		}
+		re, err := compileCodeownersPattern(pattern)
+		if err != nil {
+			fail("%s", err)
+			continue
+		}
+		rule := &codeownersRule{Pattern: pattern, Owners: owners, Line: n, re: re}
+		if section != nil {
+			rule.Section = section.Name
+		}
+		co.Rules = append(co.Rules, rule)
+	}
+	return co
+}
+
+// splitCodeownersLine splits a rule into its pattern and owners. Spaces
+// in the pattern are escaped with a backslash, as is a leading #.
+func splitCodeownersLine(line string) (string, []string) {
+	var pattern strings.Builder
+	i := 0
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) {
+			i++
+			pattern.WriteByte(line[i])
+			continue
+		}
+		if c == ' ' || c == '\t' {
+			break
+		}
+		pattern.WriteByte(c)
+	}
+	owners := strings.Fields(line[i:])
+	for j, o := range owners {
+		if strings.HasPrefix(o, "#") {
+			owners = owners[:j]
+			break
+		}
+	}
+	return pattern.String(), owners
+}
+
+func invalidOwners(owners []string) []string {
+	var bad []string
+	for _, o := range owners {
+		if !codeownersOwnerRe.MatchString(o) {
+			bad = append(bad, o)
+		}
+	}
+	return bad
+}
+
+// compileCodeownersPattern turns a CODEOWNERS pattern into a regexp over
+// slash-separated paths relative to the repository root. As in
+// .gitignore, a pattern with a slash at the start or in the middle is
+// anchored to the root, and anything else matches at any depth; a
+// pattern naming a directory matches everything in it. Unlike
+// .gitignore, "docs/*" matches only the files directly in docs.
+func compileCodeownersPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" || strings.HasPrefix(pattern, "!") || strings.ContainsAny(pattern, "[]") {
+		return nil, fmt.Errorf("unsupported pattern %q", pattern)
+	}
+
+	trimmed := strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("unsupported pattern %q", pattern)
+	}
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(trimmed, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(trimmed); i++ {
+		switch c := trimmed[i]; {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		b.WriteString("/.*$")
+	case strings.HasSuffix(trimmed, "/*") && !strings.HasSuffix(trimmed, "/**/*"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// ownersOf applies the rules to file. Within each section the last
+// matching rule wins, and a rule without owners leaves the file unowned,
+// or owned by the section's default owners in GitLab. The owners of
+// every section are combined.
+func (co *codeownersFile) ownersOf(file string) *fileOwnership {
+	res := &fileOwnership{File: file, Codeowners: co.Path, Owners: []string{}, Rules: []*codeownersRule{}}
+
+	winners := map[string]*codeownersRule{}
+	var order []string
+	for _, rule := range co.Rules {
+		if !rule.re.MatchString(file) {
+			continue
+		}
+		if _, ok := winners[rule.Section]; !ok {
+			order = append(order, rule.Section)
+		}
+		winners[rule.Section] = rule
+	}
+
+	defaults := map[string][]string{}
+	for _, s := range co.Sections {
+		defaults[s.Name] = s.DefaultOwners
+	}
+	seen := map[string]bool{}
+	for _, section := range order {
+		rule := winners[section]
+		res.Rules = append(res.Rules, rule)
+		owners := rule.Owners
+		if len(owners) == 0 {
+			owners = defaults[section]
+		}
+		for _, o := range owners {
+			if !seen[strings.ToLower(o)] {
+				seen[strings.ToLower(o)] = true
+				res.Owners = append(res.Owners, o)
+			}
+		}
+	}
+	return res
+}
+
+// repoRelative makes file relative to the repository root, the way
+// CODEOWNERS patterns are written
+func repoRelative(root, file string) string {
+	if filepath.IsAbs(file) {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+	}
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(file)), "/")
+}
+
+// findCodeowners returns the CODEOWNERS file of the repository around
+// the working directory, or of the working directory itself outside a
+// repository, and the root that patterns are relative to. Returns nil if
+// there is none.
+func (r *Root) findCodeowners() (*codeownersFile, string, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	g, err := r.findGitRepo()
+	if err != nil {
+		return nil, "", err
+	}
+	if g != nil {
+		root = g.workTree
+	}
+
+	for _, loc := range codeownersLocations {
+		file := filepath.Join(root, filepath.FromSlash(loc))
+		data, err := r.readFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", r.fileError(err)
+		}
+		return parseCodeowners(file, data), root, nil
+	}
+	return nil, "", nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "codeowners",
+			Args:        []argSpec{{"path", "string"}},
+			Returns:     "map",
+			Description: "Rules of a GitHub or GitLab CODEOWNERS file, with their owners, line and GitLab section, the sections with their default owners and approval counts, and the lines that could not be parsed. Undefined if the file cannot be read.",
+			Example:     `length(pd.codeowners("./.github/CODEOWNERS").errors) is 0`,
+			Shape:       (*codeownersFile)(nil),
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path string) (interface{}, error) {
+					data, err := r.readFile(path)
+					if err != nil {
+						return nil, r.fileError(err)
+					}
+					return parseCodeowners(path, data), nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "owners_of",
+			Args:        []argSpec{{"file", "string"}},
+			Returns:     "map",
+			Description: "Owners of a file, relative to the repository root, from the repository's CODEOWNERS file: the last matching rule wins, and the owners of each GitLab section are combined. Undefined if the repository has no CODEOWNERS file.",
+			Example:     `length(pd.owners_of("modules/network/main.tf").owners) > 0`,
+			Shape:       (*fileOwnership)(nil),
+			New: func(r *Root) interface{} {
+				return func(file string) (interface{}, error) {
+					co, root, err := r.findCodeowners()
+					if err != nil || co == nil {
+						return nil, err
+					}
+					return co.ownersOf(repoRelative(root, file)), nil
+				}
+			},
+		},
+	)
+}
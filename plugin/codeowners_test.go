@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCodeownersPatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"*", []string{"a", "x/y/z.tf"}, nil},
+		{"*.tf", []string{"main.tf", "modules/vpc/main.tf"}, []string{"main.tfvars"}},
+		{"/docs/", []string{"docs/a.md", "docs/x/b.md"}, []string{"docs", "src/docs/a.md"}},
+		{"apps/", []string{"apps/a", "src/apps/b/c"}, []string{"apps"}},
+		{"docs/*", []string{"docs/a.md"}, []string{"docs/x/b.md", "src/docs/a.md"}},
+		{"/build/logs", []string{"build/logs", "build/logs/a.log"}, []string{"x/build/logs"}},
+		{"modules/network", []string{"modules/network/main.tf"}, []string{"x/modules/network/main.tf", "modules/network2/a"}},
+		{"**/logs", []string{"logs/a", "x/y/logs/b"}, []string{"logs2/a"}},
+		{"docs/**/*.md", []string{"docs/a.md", "docs/x/y/b.md"}, []string{"docs/a.txt"}},
+		{"file?.txt", []string{"file1.txt", "x/fileA.txt"}, []string{"file10.txt"}},
+	}
+	for _, c := range cases {
+		re, err := compileCodeownersPattern(c.pattern)
+		if err != nil {
+			t.Errorf("%s: should not return error: %v", c.pattern, err)
+			continue
+		}
+		for _, p := range c.match {
+			if !re.MatchString(p) {
+				t.Errorf("%s: expected to match %s (%s)", c.pattern, p, re)
+			}
+		}
+		for _, p := range c.noMatch {
+			if re.MatchString(p) {
+				t.Errorf("%s: expected not to match %s (%s)", c.pattern, p, re)
+			}
+		}
+	}
+
+	for _, pattern := range []string{"!docs", "[abc].txt", "/"} {
+		if _, err := compileCodeownersPattern(pattern); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+}
+
+func TestCodeowners(t *testing.T) {
+	github := `# Default owners
+*                 @org/platform
+*.tf              @org/terraform jane@example.com
+/modules/network/ @org/network # network team
+/modules/network/README.md
+docs\ folder/     @docs
+!secrets          @org/security
+/legacy/          not-an-owner
+`
+
+	gitlab := `* @org/platform
+
+[Network][2] @org/network
+/modules/network/
+/modules/network/firewall.tf @org/security
+
+^[Docs] @docs
+*.md
+
+[network]
+/modules/network/README.md @writer
+`
+
+	// Test that rules, owners and skipped lines are parsed
+	t.Run("Parse", func(t *testing.T) {
+		co := parseCodeowners("CODEOWNERS", []byte(github))
+		if len(co.Rules) != 5 {
+			t.Fatalf("Expected 5 rules, got %d", len(co.Rules))
+		}
+		if r := co.Rules[1]; r.Pattern != "*.tf" || r.Line != 3 || !reflect.DeepEqual(r.Owners, []string{"@org/terraform", "jane@example.com"}) {
+			t.Errorf("Unexpected rule %+v", r)
+		}
+		if r := co.Rules[2]; !reflect.DeepEqual(r.Owners, []string{"@org/network"}) {
+			t.Errorf("Expected the trailing comment to be dropped, got %+v", r)
+		}
+		if r := co.Rules[4]; r.Pattern != "docs folder/" {
+			t.Errorf("Expected an escaped space in the pattern, got %q", r.Pattern)
+		}
+		if len(co.Errors) != 2 || co.Errors[0].Line != 7 || co.Errors[1].Line != 8 {
+			t.Errorf("Expected errors on lines 7 and 8, got %+v", co.Errors)
+		}
+	})
+
+	// Test that the last matching rule wins, including rules without owners
+	t.Run("LastMatchWins", func(t *testing.T) {
+		co := parseCodeowners("CODEOWNERS", []byte(github))
+		for file, want := range map[string][]string{
+			"go.mod":                    {"@org/platform"},
+			"modules/vpc/main.tf":       {"@org/terraform", "jane@example.com"},
+			"modules/network/main.tf":   {"@org/network"},
+			"modules/network/README.md": {},
+			"docs folder/index.md":      {"@docs"},
+		} {
+			if got := co.ownersOf(file).Owners; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %v, got %v", file, want, got)
+			}
+		}
+	})
+
+	// Test that GitLab sections each pick their own rule, use their
+	// default owners and are combined
+	t.Run("Sections", func(t *testing.T) {
+		co := parseCodeowners("CODEOWNERS", []byte(gitlab))
+		want := []*codeownersSection{
+			{Name: "Network", Approvals: 2, DefaultOwners: []string{"@org/network"}},
+			{Name: "Docs", Optional: true, DefaultOwners: []string{"@docs"}},
+		}
+		if !reflect.DeepEqual(co.Sections, want) {
+			t.Errorf("Unexpected sections %+v, %+v", co.Sections[0], co.Sections[1])
+		}
+
+		for file, want := range map[string][]string{
+			"main.tf":                     {"@org/platform"},
+			"modules/network/main.tf":     {"@org/platform", "@org/network"},
+			"modules/network/firewall.tf": {"@org/platform", "@org/security"},
+			"modules/network/README.md":   {"@org/platform", "@writer", "@docs"},
+		} {
+			if got := co.ownersOf(file).Owners; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %v, got %v", file, want, got)
+			}
+		}
+
+		res := co.ownersOf("modules/network/README.md")
+		if len(res.Rules) != 3 || res.Rules[1].Line != 11 || res.Rules[1].Section != "Network" {
+			t.Errorf("Unexpected winning rules %+v", res.Rules)
+		}
+	})
+
+	// Test that owners_of finds CODEOWNERS at the repository root
+	t.Run("OwnersOf", func(t *testing.T) {
+		dir := gitFixture(t)
+		os.MkdirAll(filepath.Join(dir, ".github"), 0755)
+		os.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte(github), 0644)
+		os.MkdirAll(filepath.Join(dir, "modules", "network"), 0755)
+		chdir(t, filepath.Join(dir, "modules", "network"))
+
+		root := &Root{}
+		root.Configure(map[string]interface{}{"log_level": "off"})
+		ownersOf := root.Func("owners_of").(func(string) (interface{}, error))
+
+		for _, file := range []string{"modules/network/main.tf", "./modules/network/main.tf", filepath.Join(dir, "modules/network/main.tf")} {
+			v, err := ownersOf(file)
+			if err != nil {
+				t.Fatalf("owners_of should not return error: %v", err)
+			}
+			res := v.(*fileOwnership)
+			if res.File != "modules/network/main.tf" || !reflect.DeepEqual(res.Owners, []string{"@org/network"}) {
+				t.Errorf("%s: unexpected ownership %+v", file, res)
+			}
+			if res.Codeowners != filepath.Join(dir, ".github", "CODEOWNERS") {
+				t.Errorf("Unexpected CODEOWNERS path %s", res.Codeowners)
+			}
+		}
+
+		v, err := root.Func("codeowners").(func(string) (interface{}, error))(filepath.Join(dir, ".github", "CODEOWNERS"))
+		if err != nil || len(v.(*codeownersFile).Rules) != 5 {
+			t.Errorf("Expected 5 rules, got %v, %v", v, err)
+		}
+
+		os.Remove(filepath.Join(dir, ".github", "CODEOWNERS"))
+		if v, err := ownersOf("main.tf"); v != nil || err != nil {
+			t.Errorf("Expected undefined without a CODEOWNERS file, got %v, %v", v, err)
+		}
+	})
+}
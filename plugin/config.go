@@ -0,0 +1,276 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Defaults used when the plugin is not configured, or a setting is omitted.
+const (
+	defaultMaxFileBytes = 32 << 20 // 32 MiB
+	defaultIOTimeout    = 10 * time.Second
+	defaultLogLevel     = hclog.Info
+	defaultLogFormat    = "json"
+
+	defaultAuditMaxBytes   = 10 << 20 // 10 MiB
+	defaultAuditMaxBackups = 3
+
+	defaultBulkWorkers = 8
+)
+
+// config holds the settings supplied through the plugin's config block
+// in sentinel.hcl:
+//
+//	import "plugin" "plugin-demo" {
+//	  source = "./bin/sentinel-plugin-demo"
+//	  config = {
+//	    max_file_bytes = 1048576
+//	    io_timeout     = "5s"
+//	    log_level      = "debug"
+//	    audit_file     = "/var/log/sentinel/plugin-demo-audit.jsonl"
+//	    metrics_file   = "/var/lib/node_exporter/plugin-demo.prom"
+//	  }
+//	}
+type config struct {
+	// MaxFileBytes is the largest number of bytes any file-reading
+	// function will return.
+	MaxFileBytes int64
+
+	// IOTimeout bounds how long a single file operation may block.
+	IOTimeout time.Duration
+
+	// LogLevel is the minimum level logged: trace, debug, info, warn,
+	// error or off. Every call is logged at debug, failures at error.
+	LogLevel hclog.Level
+
+	// LogFormat is json, which the Sentinel host understands, or text
+	// for running the plugin by hand.
+	LogFormat string
+
+	// AuditFile is the JSON Lines file every environment variable and file
+	// access is recorded to. Auditing is off when empty.
+	AuditFile string
+
+	// AuditMaxBytes is the size at which the audit file is rotated, and
+	// AuditMaxBackups how many rotated files are kept.
+	AuditMaxBytes   int64
+	AuditMaxBackups int
+
+	// MetricsFile is where the call metrics are written in the Prometheus
+	// textfile collector format when the plugin shuts down. Off when empty.
+	// Each plugin instance replaces the file with its own counters, so it
+	// holds those of the instance that closed last.
+	MetricsFile string
+
+	// CacheSize is how many call results are kept for reuse, see cache.go.
+	// The cache is off when zero.
+	CacheSize int
+
+	// BulkWorkers is how many files getfiles, hashfiles and statmany work
+	// on at once.
+	BulkWorkers int
+
+	// SigningKeys maps key names usable with verify_signature to a public
+	// key, either its path or the key itself.
+	SigningKeys map[string]string
+
+	// SecretRules are extra scan_secrets rules, compiled from a map of rule
+	// ID to regular expression and sorted by ID.
+	SecretRules []*secretRule
+}
+
+func defaultConfig() *config {
+	return &config{
+		MaxFileBytes: defaultMaxFileBytes,
+		IOTimeout:    defaultIOTimeout,
+		LogLevel:     defaultLogLevel,
+		LogFormat:    defaultLogFormat,
+
+		AuditMaxBytes:   defaultAuditMaxBytes,
+		AuditMaxBackups: defaultAuditMaxBackups,
+
+		BulkWorkers: defaultBulkWorkers,
+	}
+}
+
+// parseConfig builds a config from the raw map passed to Configure,
+// falling back to the defaults for anything not set.
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := defaultConfig()
+
+	if v, ok := m["max_file_bytes"]; ok {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("max_file_bytes: %s", err)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("max_file_bytes: must be positive, got %d", n)
+		}
+		c.MaxFileBytes = n
+	}
+
+	if v, ok := m["io_timeout"]; ok {
+		d, err := toDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("io_timeout: %s", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("io_timeout: must be positive, got %s", d)
+		}
+		c.IOTimeout = d
+	}
+
+	if v, ok := m["log_level"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("log_level: expected a string, got %T", v)
+		}
+		level := hclog.LevelFromString(s)
+		if level == hclog.NoLevel {
+			return nil, fmt.Errorf("log_level: unknown level %q", s)
+		}
+		c.LogLevel = level
+	}
+
+	if v, ok := m["log_format"]; ok {
+		s, _ := v.(string)
+		if s != "json" && s != "text" {
+			return nil, fmt.Errorf("log_format: expected json or text, got %v", v)
+		}
+		c.LogFormat = s
+	}
+
+	if v, ok := m["audit_file"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("audit_file: expected a string, got %T", v)
+		}
+		c.AuditFile = s
+	}
+
+	if v, ok := m["metrics_file"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("metrics_file: expected a string, got %T", v)
+		}
+		c.MetricsFile = s
+	}
+
+	if v, ok := m["audit_max_bytes"]; ok {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("audit_max_bytes: %s", err)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("audit_max_bytes: must be positive, got %d", n)
+		}
+		c.AuditMaxBytes = n
+	}
+
+	if v, ok := m["audit_max_backups"]; ok {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("audit_max_backups: %s", err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("audit_max_backups: must not be negative, got %d", n)
+		}
+		c.AuditMaxBackups = int(n)
+	}
+
+	if v, ok := m["cache_size"]; ok {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("cache_size: %s", err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("cache_size: must not be negative, got %d", n)
+		}
+		c.CacheSize = int(n)
+	}
+
+	if v, ok := m["bulk_workers"]; ok {
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("bulk_workers: %s", err)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("bulk_workers: must be positive, got %d", n)
+		}
+		c.BulkWorkers = int(n)
+	}
+
+	if v, ok := m["signing_keys"]; ok {
+		keys, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("signing_keys: expected a map, got %T", v)
+		}
+		c.SigningKeys = make(map[string]string, len(keys))
+		for name, key := range keys {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("signing_keys: %s: expected a string, got %T", name, key)
+			}
+			c.SigningKeys[name] = s
+		}
+	}
+
+	if v, ok := m["secret_rules"]; ok {
+		rules, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("secret_rules: expected a map, got %T", v)
+		}
+		for _, id := range sortedKeys(rules) {
+			expr, ok := rules[id].(string)
+			if !ok {
+				return nil, fmt.Errorf("secret_rules: %s: expected a string, got %T", id, rules[id])
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("secret_rules: %s: %s", id, err)
+			}
+			c.SecretRules = append(c.SecretRules, &secretRule{ID: id, re: re})
+		}
+	}
+
+	return c, nil
+}
+
+// toInt64 converts the numeric types Sentinel may hand us into an int64.
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		return int64(n), nil
+	case float64:
+		if n != float64(int64(n)) {
+			return 0, fmt.Errorf("expected a whole number, got %v", n)
+		}
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+// toDuration accepts either a Go duration string ("5s", "250ms") or a
+// number of seconds.
+func toDuration(v interface{}) (time.Duration, error) {
+	if s, ok := v.(string); ok {
+		return time.ParseDuration(s)
+	}
+	if f, ok := v.(float64); ok {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("expected a duration string or seconds, got %T", v)
+	}
+	return time.Duration(n) * time.Second, nil
+}
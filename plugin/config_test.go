@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	// Test that an empty config uses the defaults
+	t.Run("UsesDefaults", func(t *testing.T) {
+		c, err := parseConfig(map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("parseConfig should not return error: %v", err)
+		}
+		if c.MaxFileBytes != defaultMaxFileBytes || c.IOTimeout != defaultIOTimeout {
+			t.Errorf("Expected defaults, got %+v", c)
+		}
+	})
+
+	// Test that durations may be strings or seconds
+	t.Run("ParsesTimeouts", func(t *testing.T) {
+		for _, v := range []interface{}{"3s", int64(3), float64(3)} {
+			c, err := parseConfig(map[string]interface{}{"io_timeout": v})
+			if err != nil {
+				t.Fatalf("parseConfig(%v) should not return error: %v", v, err)
+			}
+			if c.IOTimeout != 3*time.Second {
+				t.Errorf("Expected 3s for %v, got %s", v, c.IOTimeout)
+			}
+		}
+	})
+
+	// Test that invalid values are rejected
+	t.Run("RejectsInvalidValues", func(t *testing.T) {
+		invalid := []map[string]interface{}{
+			{"max_file_bytes": "big"},
+			{"max_file_bytes": int64(0)},
+			{"io_timeout": "soon"},
+			{"io_timeout": "-1s"},
+			{"log_level": "loud"},
+			{"log_format": "xml"},
+			{"cache_size": int64(-1)},
+			{"bulk_workers": int64(0)},
+		}
+		for _, m := range invalid {
+			if _, err := parseConfig(m); err == nil {
+				t.Errorf("parseConfig(%v) should return error", m)
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// codec is a pair of functions converting between raw bytes and text
+type codec struct {
+	encode func([]byte) string
+	decode func(string) ([]byte, error)
+}
+
+// codecs are the encodings supported by encode and decode
+var codecs = map[string]codec{
+	"base64":       {base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString},
+	"base64url":    {base64.URLEncoding.EncodeToString, base64.URLEncoding.DecodeString},
+	"base64raw":    {base64.RawStdEncoding.EncodeToString, base64.RawStdEncoding.DecodeString},
+	"base64rawurl": {base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString},
+	"hex":          {hex.EncodeToString, hex.DecodeString},
+	"percent": {
+		func(b []byte) string { return url.PathEscape(string(b)) },
+		func(s string) ([]byte, error) {
+			v, err := url.PathUnescape(s)
+			return []byte(v), err
+		},
+	},
+	"query": {
+		func(b []byte) string { return url.QueryEscape(string(b)) },
+		func(s string) ([]byte, error) {
+			v, err := url.QueryUnescape(s)
+			return []byte(v), err
+		},
+	},
+}
+
+func lookupCodec(name string) (codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		names := make([]string, 0, len(codecs))
+		for n := range codecs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return codec{}, &kindError{
+			Kind: kindInvalidArgument,
+			Err:  fmt.Errorf("unknown encoding %q, expected one of %s", name, strings.Join(names, ", ")),
+		}
+	}
+	return c, nil
+}
+
+// encodeString encodes value with the named encoding
+func encodeString(value, encoding string) (string, error) {
+	c, err := lookupCodec(encoding)
+	if err != nil {
+		return "", err
+	}
+	return c.encode([]byte(value)), nil
+}
+
+// decodeString decodes value with the named encoding. Malformed input is
+// always an error rather than undefined, so a policy cannot mistake it for
+// an empty value.
+func decodeString(value, encoding string) (string, error) {
+	c, err := lookupCodec(encoding)
+	if err != nil {
+		return "", err
+	}
+	b, err := c.decode(value)
+	if err != nil {
+		return "", &kindError{Kind: kindDecode, Err: fmt.Errorf("invalid %s: %s", encoding, err)}
+	}
+	return string(b), nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "encode",
+			Args:        []argSpec{{"value", "string"}, {"encoding", "string"}},
+			Returns:     "string",
+			Description: "Encodes a string with base64, base64url, base64raw, base64rawurl, hex, percent or query encoding.",
+			Example:     `pd.encode("a b", "percent")`,
+			New: func(r *Root) interface{} {
+				return func(value string, encoding string) (interface{}, error) {
+					encoded, err := encodeString(value, encoding)
+					if err != nil {
+						return nil, err
+					}
+					return &encoded, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "decode",
+			Args:        []argSpec{{"value", "string"}, {"encoding", "string"}},
+			Returns:     "string",
+			Description: "Decodes a string, failing with a decode error if it is not valid for the encoding.",
+			Example:     `pd.decode("aGVsbG8=", "base64")`,
+			New: func(r *Root) interface{} {
+				return func(value string, encoding string) (interface{}, error) {
+					decoded, err := decodeString(value, encoding)
+					if err != nil {
+						return nil, err
+					}
+					return &decoded, nil
+				}
+			},
+		},
+	)
+}
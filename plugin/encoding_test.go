@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	root := &Root{}
+	encode := root.Func("encode").(func(string, string) (interface{}, error))
+	decode := root.Func("decode").(func(string, string) (interface{}, error))
+
+	cases := []struct {
+		encoding string
+		value    string
+		encoded  string
+	}{
+		{"base64", "hi?>", "aGk/Pg=="},
+		{"base64url", "hi?>", "aGk_Pg=="},
+		{"base64raw", "hi?>", "aGk/Pg"},
+		{"base64rawurl", "hi?>", "aGk_Pg"},
+		{"hex", "\x00\xff", "00ff"},
+		{"percent", "a b/c", "a%20b%2Fc"},
+		{"query", "a b&c", "a+b%26c"},
+	}
+
+	// Test that every encoding round trips
+	t.Run("RoundTrips", func(t *testing.T) {
+		for _, tc := range cases {
+			result, err := encode(tc.value, tc.encoding)
+			if err != nil {
+				t.Fatalf("%s: encode should not return error: %v", tc.encoding, err)
+			}
+			if *result.(*string) != tc.encoded {
+				t.Errorf("%s: expected %s, got %s", tc.encoding, tc.encoded, *result.(*string))
+			}
+
+			result, err = decode(tc.encoded, tc.encoding)
+			if err != nil {
+				t.Fatalf("%s: decode should not return error: %v", tc.encoding, err)
+			}
+			if *result.(*string) != tc.value {
+				t.Errorf("%s: expected %q, got %q", tc.encoding, tc.value, *result.(*string))
+			}
+		}
+	})
+
+	// Test that malformed input returns a decode error
+	t.Run("ReturnsDecodeErrors", func(t *testing.T) {
+		for encoding, value := range map[string]string{
+			"base64":  "not base64!",
+			"hex":     "zz",
+			"percent": "%zz",
+		} {
+			result, err := decode(value, encoding)
+			if result != nil {
+				t.Errorf("%s: decode should not return a value for malformed input", encoding)
+			}
+			if errorKind(err) != kindDecode {
+				t.Errorf("%s: expected %s error, got %v", encoding, kindDecode, err)
+			}
+		}
+	})
+
+	// Test that unknown encodings are rejected
+	t.Run("RejectsUnknownEncoding", func(t *testing.T) {
+		if _, err := encode("x", "rot13"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+		if _, err := decode("x", "rot13"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+}
+
+func TestGetFileBase64(t *testing.T) {
+	root := &Root{}
+
+	// Test that binary content is returned as base64
+	t.Run("EncodesBinaryContent", func(t *testing.T) {
+		tempFile := filepath.Join(t.TempDir(), "binary.bin")
+		if err := os.WriteFile(tempFile, []byte{0x00, 0x01, 0x02, 0xFF, 0xFE, 0xFD}, 0644); err != nil {
+			t.Fatalf("Failed to create binary test file: %v", err)
+		}
+
+		callable := root.Func("getfile_base64").(func(string) (interface{}, error))
+		result, err := callable(tempFile)
+		if err != nil {
+			t.Fatalf("getfile_base64 should not return error: %v", err)
+		}
+		if *result.(*string) != "AAEC//79" {
+			t.Errorf("Expected AAEC//79, got %s", *result.(*string))
+		}
+	})
+
+	// Test that missing files return nil
+	t.Run("ReturnsNilForNonExistentFile", func(t *testing.T) {
+		callable := root.Func("getfile_base64").(func(string) (interface{}, error))
+		result, err := callable("/absolutely/non/existent/file/path/12345.bin")
+		if result != nil || err != nil {
+			t.Errorf("Expected nil, nil for missing file, got %v, %v", result, err)
+		}
+	})
+}
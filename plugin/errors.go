@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds reported back to Sentinel. The kind is always the first
+// token of the error message so policies and operators can match on it.
+const (
+	kindTooLarge        = "too_large"
+	kindTimeout         = "timeout"
+	kindInvalidArchive  = "invalid_archive"
+	kindUnsafeMember    = "unsafe_member"
+	kindInvalidArgument = "invalid_argument"
+	kindDecode          = "decode"
+	kindVersionMismatch = "version_mismatch"
+	kindInvalidKey      = "invalid_key"
+)
+
+// kindError is an error tagged with a stable kind, such as "too_large"
+// or "timeout", and the path (if any) that caused it.
+type kindError struct {
+	Kind string
+	Path string
+	Err  error
+}
+
+func (e *kindError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Kind, e.Path, e.Err)
+}
+
+func (e *kindError) Unwrap() error {
+	return e.Err
+}
+
+// errorKind returns the kind of err, or an empty string if err was not
+// produced by the plugin itself (e.g. a plain os error).
+func errorKind(err error) string {
+	var ke *kindError
+	if errors.As(err, &ke) {
+		return ke.Kind
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// readFile reads the file at path, enforcing the configured size limit
+// and I/O timeout, and records the access in the audit log. Every function
+// that reads files goes through here so the limits apply uniformly.
+func (r *Root) readFile(path string) ([]byte, error) {
+	c := r.conf()
+	data, err := withTimeout(c.IOTimeout, path, func() ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return readLimited(f, c.MaxFileBytes, path)
+	})
+	r.audit("file", path, err)
+	r.stats().addBytesRead(len(data))
+	return data, err
+}
+
+// readLimited reads all of src, failing with a too_large error once more
+// than limit bytes have been read.
+func readLimited(src io.Reader, limit int64, path string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(src, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &kindError{
+			Kind: kindTooLarge,
+			Path: path,
+			Err:  fmt.Errorf("exceeds max_file_bytes (%d)", limit),
+		}
+	}
+	return data, nil
+}
+
+// withTimeout runs fn in its own goroutine and gives up after d. A call
+// that is stuck in the kernel (a FIFO with no writer, a hung network
+// mount) cannot be interrupted, so the goroutine is left to finish on its
+// own; what matters is that policy evaluation does not hang with it.
+func withTimeout[T any](d time.Duration, path string, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-timer.C:
+		var zero T
+		return zero, &kindError{
+			Kind: kindTimeout,
+			Path: path,
+			Err:  fmt.Errorf("no response within io_timeout (%s)", d),
+		}
+	}
+}
+
+// fileResult converts the result of a file read into a string for Sentinel
+func (r *Root) fileResult(contents []byte, err error) (interface{}, error) {
+	if err != nil {
+		return nil, r.fileError(err)
+	}
+	contentsStr := string(contents)
+	return &contentsStr, nil
+}
+
+// fileError returns plugin errors such as too_large as-is, and swallows
+// anything else (file not found or inaccessible) so the result is
+// undefined. Swallowed errors are logged so they do not just disappear.
+func (r *Root) fileError(err error) error {
+	if errorKind(err) != "" {
+		return err
+	}
+	r.log().Debug("file read failed, returning undefined", "error", err)
+	return nil
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "getfile_base64",
+		Args:        []argSpec{{"path", "string"}},
+		Returns:     "string",
+		Description: "Contents of a file encoded as standard base64, for binary files. Same limits as getfile.",
+		Example:     `pd.getfile_base64("./logo.png")`,
+		Cache:       cacheByFile,
+		Audit:       "file",
+		New: func(r *Root) interface{} {
+			return func(path string) (interface{}, error) {
+				contents, err := r.readFile(path)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+				encoded := base64.StdEncoding.EncodeToString(contents)
+				return &encoded, nil
+			}
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLimits(t *testing.T) {
+	// Create a root with a small size limit
+	root := &Root{}
+	if err := root.Configure(map[string]interface{}{"max_file_bytes": 8}); err != nil {
+		t.Fatalf("Configure should not return error: %v", err)
+	}
+
+	// Test that files within the limit are read in full
+	t.Run("ReadsFileWithinLimit", func(t *testing.T) {
+		tempFile := filepath.Join(t.TempDir(), "small.txt")
+		if err := os.WriteFile(tempFile, []byte("12345678"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		data, err := root.readFile(tempFile)
+		if err != nil {
+			t.Fatalf("readFile should not return error: %v", err)
+		}
+		if string(data) != "12345678" {
+			t.Errorf("Expected 12345678, got %s", data)
+		}
+	})
+
+	// Test that getfile returns a too_large error for oversized files
+	t.Run("GetFileReturnsTooLarge", func(t *testing.T) {
+		tempFile := filepath.Join(t.TempDir(), "large.txt")
+		if err := os.WriteFile(tempFile, []byte("123456789"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		callable := root.Func("getfile").(func(string) (interface{}, error))
+		result, err := callable(tempFile)
+		if result != nil {
+			t.Error("getfile should not return contents for oversized files")
+		}
+		if errorKind(err) != kindTooLarge {
+			t.Errorf("Expected %s error, got %v", kindTooLarge, err)
+		}
+	})
+
+	// Test that getfile still returns nil without error for missing files
+	t.Run("MissingFileIsNotAnError", func(t *testing.T) {
+		callable := root.Func("getfile").(func(string) (interface{}, error))
+		result, err := callable("/absolutely/non/existent/file/path/12345.txt")
+		if result != nil || err != nil {
+			t.Errorf("Expected nil, nil for missing file, got %v, %v", result, err)
+		}
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	// Test that a blocked operation returns a timeout error
+	t.Run("ReturnsTimeoutWhenBlocked", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		start := time.Now()
+		_, err := withTimeout(10*time.Millisecond, "/blocked", func() ([]byte, error) {
+			<-block
+			return nil, nil
+		})
+		if errorKind(err) != kindTimeout {
+			t.Fatalf("Expected %s error, got %v", kindTimeout, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("withTimeout should return promptly after the timeout")
+		}
+	})
+
+	// Test that a fast operation returns its result
+	t.Run("ReturnsResultWhenFast", func(t *testing.T) {
+		v, err := withTimeout(time.Second, "/fast", func() (string, error) {
+			return "ok", nil
+		})
+		if err != nil {
+			t.Fatalf("withTimeout should not return error: %v", err)
+		}
+		if v != "ok" {
+			t.Errorf("Expected ok, got %s", v)
+		}
+	})
+}
@@ -0,0 +1,575 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Deepest chain of symbolic refs or nested tags followed
+const gitMaxIndirection = 10
+
+var (
+	gitFullID    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	gitAbbrevID  = regexp.MustCompile(`^[0-9a-f]{4,39}$`)
+	gitPseudoRef = regexp.MustCompile(`^[A-Z_]+$`) // HEAD, ORIG_HEAD, FETCH_HEAD...
+)
+
+// Return structs
+type gitCommit struct {
+	SHA       string `sentinel:"sha"`
+	Tree      string
+	Parents   []string
+	Author    *gitSignature
+	Committer *gitSignature
+	Message   string
+}
+
+type gitSignature struct {
+	Name  string
+	Email string
+	Time  string // RFC 3339, in the committer's time zone
+}
+
+// gitRepo is the repository around the working directory, read straight
+// from .git rather than by running git, which may not be installed where
+// policies are evaluated
+type gitRepo struct {
+	r        *Root
+	workTree string // the checkout, where .git was found
+	gitDir   string // HEAD, which is per worktree
+	common   string // refs, objects and config, shared by all worktrees
+
+	objects *gitObjects // loaded on first use
+}
+
+// findGitRepo looks for .git in the working directory and its parents.
+// Returns nil if there is none.
+func (r *Root) findGitRepo() (*gitRepo, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ".git")
+		info, err := os.Stat(path)
+		if err == nil {
+			return r.openGitRepo(dir, path, info)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// openGitRepo opens the .git at path. In linked worktrees and submodules
+// .git is a file pointing at the real git directory, which in turn may
+// point at the directory it shares with the main worktree.
+func (r *Root) openGitRepo(dir, path string, info fs.FileInfo) (*gitRepo, error) {
+	repo := &gitRepo{r: r, workTree: dir, gitDir: path}
+	if !info.IsDir() {
+		data, err := r.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+		if !ok {
+			return nil, &kindError{Kind: kindDecode, Path: path, Err: errors.New(`expected "gitdir: <path>"`)}
+		}
+		repo.gitDir = resolveGitPath(dir, target)
+	}
+
+	repo.common = repo.gitDir
+	data, err := r.readFile(filepath.Join(repo.gitDir, "commondir"))
+	switch {
+	case err == nil:
+		repo.common = resolveGitPath(repo.gitDir, strings.TrimSpace(string(data)))
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return repo, nil
+}
+
+func resolveGitPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+func (g *gitRepo) objectDB() (*gitObjects, error) {
+	if g.objects == nil {
+		o, err := g.r.openGitObjects(filepath.Join(g.common, "objects"))
+		if err != nil {
+			return nil, err
+		}
+		g.objects = o
+	}
+	return g.objects, nil
+}
+
+// readRef returns the contents of a loose ref, and whether it exists.
+// HEAD and other pseudo-refs live in the worktree's git directory, refs/
+// in the common one.
+func (g *gitRepo) readRef(name string) (string, bool, error) {
+	dir := g.common
+	if !strings.HasPrefix(name, "refs/") {
+		dir = g.gitDir
+	}
+	data, err := g.r.readFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.EISDIR) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// packedRefs reads packed-refs: the ID each ref points to, and for
+// annotated tags the commit it peels to, from the "^" line that follows
+func (g *gitRepo) packedRefs() (refs, peeled map[string]string, err error) {
+	refs, peeled = map[string]string{}, map[string]string{}
+	path := filepath.Join(g.common, "packed-refs")
+	data, err := g.r.readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return refs, peeled, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var last string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if last != "" {
+				peeled[last] = line[1:]
+			}
+		default:
+			id, name, ok := strings.Cut(line, " ")
+			if !ok || !gitFullID.MatchString(id) {
+				return nil, nil, &kindError{Kind: kindDecode, Path: path, Err: fmt.Errorf("malformed line %q", line)}
+			}
+			refs[name] = id
+			last = name
+		}
+	}
+	return refs, peeled, nil
+}
+
+// resolveRef follows name, through symbolic refs, to an object ID.
+// Returns "" if it does not exist, as for the HEAD of an empty repository.
+func (g *gitRepo) resolveRef(name string) (string, error) {
+	var packed map[string]string
+	for i := 0; i < gitMaxIndirection; i++ {
+		value, ok, err := g.readRef(name)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if packed == nil {
+				if packed, _, err = g.packedRefs(); err != nil {
+					return "", err
+				}
+			}
+			return packed[name], nil
+		}
+		target, symbolic := strings.CutPrefix(value, "ref: ")
+		if !symbolic {
+			return value, nil
+		}
+		name = target
+	}
+	return "", &kindError{Kind: kindDecode, Path: g.gitDir, Err: fmt.Errorf("symbolic ref %q nested too deeply", name)}
+}
+
+// branch returns the branch HEAD is on, or "" if it is detached
+func (g *gitRepo) branch() (string, error) {
+	value, _, err := g.readRef("HEAD")
+	if err != nil {
+		return "", err
+	}
+	target, _ := strings.CutPrefix(value, "ref: ")
+	name, _ := strings.CutPrefix(target, "refs/heads/")
+	if name == target {
+		return "", nil
+	}
+	return name, nil
+}
+
+// refs returns every ref under prefix, e.g. "refs/tags/", loose refs
+// taking precedence over packed ones
+func (g *gitRepo) refs(prefix string) (refs, peeled map[string]string, err error) {
+	refs, peeled, err = g.packedRefs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for name := range refs {
+		if !strings.HasPrefix(name, prefix) {
+			delete(refs, name)
+		}
+	}
+
+	root := filepath.Join(g.common, filepath.FromSlash(prefix))
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(g.common, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		id, err := g.resolveRef(name)
+		if err != nil {
+			return err
+		}
+		if id != refs[name] {
+			delete(peeled, name)
+		}
+		refs[name] = id
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return refs, peeled, nil
+}
+
+// resolveRevision turns a full or abbreviated object ID, HEAD, or a ref
+// name into an object ID, trying ref names in the order git does.
+// Returns "" if nothing matches.
+func (g *gitRepo) resolveRevision(rev string) (string, error) {
+	if gitFullID.MatchString(rev) {
+		return rev, nil
+	}
+	if rev == "" || strings.Contains(rev, "..") {
+		return "", nil
+	}
+
+	candidates := []string{"refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev, "refs/remotes/" + rev, "refs/remotes/" + rev + "/HEAD"}
+	if gitPseudoRef.MatchString(rev) || strings.HasPrefix(rev, "refs/") {
+		candidates = append([]string{rev}, candidates...)
+	}
+	for _, name := range candidates {
+		id, err := g.resolveRef(name)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+
+	if !gitAbbrevID.MatchString(rev) {
+		return "", nil
+	}
+	o, err := g.objectDB()
+	if err != nil {
+		return "", err
+	}
+	ids, err := o.expand(rev)
+	if err != nil {
+		return "", err
+	}
+	switch len(ids) {
+	case 0:
+		return "", nil
+	case 1:
+		return ids[0], nil
+	}
+	return "", &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("abbreviated ID %q is ambiguous", rev)}
+}
+
+// peel follows annotated tags to the object they point at, returning its
+// type, ID and contents
+func (g *gitRepo) peel(id string) (string, string, []byte, error) {
+	o, err := g.objectDB()
+	if err != nil {
+		return "", "", nil, err
+	}
+	for i := 0; i < gitMaxIndirection; i++ {
+		typ, data, err := o.read(id)
+		if err != nil || typ != "tag" {
+			return typ, id, data, err
+		}
+		target, _, _ := strings.Cut(string(data), "\n")
+		id, _ = strings.CutPrefix(target, "object ")
+	}
+	return "", "", nil, &kindError{Kind: kindDecode, Path: g.common, Err: fmt.Errorf("tag %s nested too deeply", id)}
+}
+
+// commit reads the commit rev resolves to, or returns nil if there is no
+// such revision or the object is missing
+func (g *gitRepo) commit(rev string) (*gitCommit, error) {
+	id, err := g.resolveRevision(rev)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	typ, id, data, err := g.peel(id)
+	if errors.Is(err, errGitNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if typ != "commit" {
+		return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("%q is a %s, not a commit", rev, typ)}
+	}
+
+	c, err := parseGitCommit(id, data)
+	if err != nil {
+		return nil, &kindError{Kind: kindDecode, Path: g.common, Err: fmt.Errorf("commit %s: %s", id, err)}
+	}
+	return c, nil
+}
+
+// parseGitCommit parses the headers and message of a commit object.
+// Headers git may add, such as gpgsig and its continuation lines, are
+// skipped.
+func parseGitCommit(id string, data []byte) (*gitCommit, error) {
+	headers, message, _ := strings.Cut(string(data), "\n\n")
+	c := &gitCommit{SHA: id, Parents: []string{}, Message: message}
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		var err error
+		switch key {
+		case "tree":
+			c.Tree = value
+		case "parent":
+			c.Parents = append(c.Parents, value)
+		case "author":
+			c.Author, err = parseGitSignature(value)
+		case "committer":
+			c.Committer, err = parseGitSignature(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !gitFullID.MatchString(c.Tree) {
+		return nil, errors.New("missing tree")
+	}
+	return c, nil
+}
+
+// parseGitSignature parses "Name <email> 1700000000 +0100"
+func parseGitSignature(s string) (*gitSignature, error) {
+	open, end := strings.LastIndex(s, "<"), strings.LastIndex(s, ">")
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed signature %q", s)
+	}
+	sig := &gitSignature{
+		Name:  strings.TrimSpace(s[:open]),
+		Email: s[open+1 : end],
+	}
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return nil, fmt.Errorf("malformed signature time %q", s[end+1:])
+	}
+	secs, err1 := strconv.ParseInt(fields[0], 10, 64)
+	hours, err2 := strconv.Atoi(fields[1][1:3])
+	mins, err3 := strconv.Atoi(fields[1][3:])
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("malformed signature time %q", s[end+1:])
+	}
+	offset := hours*3600 + mins*60
+	if fields[1][0] == '-' {
+		offset = -offset
+	}
+	sig.Time = time.Unix(secs, 0).In(time.FixedZone("", offset)).Format(time.RFC3339)
+	return sig, nil
+}
+
+// tagsAtHead returns the names of the tags pointing at the HEAD commit,
+// lightweight or annotated
+func (g *gitRepo) tagsAtHead() ([]string, error) {
+	head, err := g.resolveRef("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	if head == "" {
+		return tags, nil
+	}
+
+	refs, peeled, err := g.refs("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	for name, id := range refs {
+		target, ok := peeled[name]
+		if !ok {
+			if _, target, _, err = g.peel(id); err != nil && !errors.Is(err, errGitNotFound) {
+				return nil, err
+			}
+		}
+		if target == head || id == head {
+			tags = append(tags, strings.TrimPrefix(name, "refs/tags/"))
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// remoteURLs reads the url of each [remote "name"] section of the
+// repository's config. Only the first url of a remote is returned.
+func (g *gitRepo) remoteURLs() (map[string]string, error) {
+	urls := map[string]string{}
+	data, err := g.r.readFile(filepath.Join(g.common, "config"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return urls, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var remote string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			remote = ""
+			section := strings.Trim(line, "[]")
+			if name, ok := strings.CutPrefix(section, "remote "); ok {
+				remote = strings.Trim(strings.TrimSpace(name), `"`)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || remote == "" || !strings.EqualFold(strings.TrimSpace(key), "url") {
+			continue
+		}
+		if _, seen := urls[remote]; !seen {
+			urls[remote] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return urls, nil
+}
+
+// gitGet runs fn against the repository around the working directory,
+// within io_timeout. Returns undefined outside a repository, and when fn
+// returns nil.
+func (r *Root) gitGet(fn func(g *gitRepo) (interface{}, error)) (interface{}, error) {
+	g, err := r.findGitRepo()
+	if err != nil || g == nil {
+		return nil, err
+	}
+	v, err := withTimeout(r.conf().IOTimeout, g.gitDir, func() (interface{}, error) {
+		return fn(g)
+	})
+	if err != nil || isNil(v) {
+		return nil, err
+	}
+	return v, nil
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "git.commit",
+		Args:        []argSpec{{"rev", "string"}},
+		Returns:     "map",
+		Description: "A commit by full or abbreviated SHA, branch, tag or HEAD, with its tree, parents, author, committer and message. Undefined if there is no such commit or no repository.",
+		Example:     `pd.git.commit("main").author.email`,
+		Shape:       (*gitCommit)(nil),
+		New: func(r *Root) interface{} {
+			return func(rev string) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					return g.commit(rev)
+				})
+			}
+		},
+	})
+
+	registerProp(
+		&propSpec{
+			Name:        "git",
+			Returns:     "namespace",
+			Description: "The git repository around the working directory, read from .git without running git: head, branch, tags_at_head, remote_urls and commit(rev). Undefined outside a repository.",
+			Example:     `pd.git.branch is "main"`,
+			Get: func(r *Root) (interface{}, error) {
+				g, err := r.findGitRepo()
+				if err != nil || g == nil {
+					return nil, err
+				}
+				return &namespace{r: r, name: "git"}, nil
+			},
+		},
+		&propSpec{
+			Name:        "git.head",
+			Returns:     "map",
+			Description: "The commit HEAD points at, see git.commit. Undefined in a repository with no commits.",
+			Example:     `pd.git.head.sha`,
+			Shape:       (*gitCommit)(nil),
+			Get: func(r *Root) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					return g.commit("HEAD")
+				})
+			},
+		},
+		&propSpec{
+			Name:        "git.branch",
+			Returns:     "string",
+			Description: "The branch checked out, without refs/heads/. Undefined if HEAD is detached, as in most CI checkouts.",
+			Example:     `pd.git.branch`,
+			Get: func(r *Root) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					branch, err := g.branch()
+					if err != nil || branch == "" {
+						return nil, err
+					}
+					return branch, nil
+				})
+			},
+		},
+		&propSpec{
+			Name:        "git.tags_at_head",
+			Returns:     "list(string)",
+			Description: "Names of the tags, lightweight or annotated, that point at the HEAD commit.",
+			Example:     `length(pd.git.tags_at_head) > 0`,
+			Get: func(r *Root) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					return g.tagsAtHead()
+				})
+			},
+		},
+		&propSpec{
+			Name:        "git.remote_urls",
+			Returns:     "map(string)",
+			Description: "URL of each remote in the repository's config, by remote name.",
+			Example:     `pd.git.remote_urls["origin"]`,
+			Shape:       map[string]string(nil),
+			Get: func(r *Root) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					return g.remoteURLs()
+				})
+			},
+		},
+	)
+}
@@ -0,0 +1,432 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// gitFixture creates a repository with two commits on main, tags and a
+// remote, and returns its directory
+func gitFixture(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	gitRun(t, dir, "init", "-q", "-b", "main")
+	gitRun(t, dir, "remote", "add", "origin", "https://example.com/org/repo.git")
+	gitRun(t, dir, "remote", "add", "upstream", "git@example.com:org/repo.git")
+
+	// A file large enough for gc to store the second version as a delta
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, strings.Repeat("resource line ", 5)+string(rune('a'+i%26)))
+	}
+	os.WriteFile(filepath.Join(dir, "main.tf"), []byte(strings.Join(lines, "\n")), 0644)
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "First commit")
+	gitRun(t, dir, "tag", "-a", "-m", "Release 1.0", "v1.0")
+
+	lines[100] = "changed"
+	os.WriteFile(filepath.Join(dir, "main.tf"), []byte(strings.Join(lines, "\n")), 0644)
+	gitRun(t, dir, "commit", "-q", "-am", "Second commit\n\nWith a body.")
+	gitRun(t, dir, "tag", "latest")
+	gitRun(t, dir, "tag", "-a", "-m", "Release 2.0", "v2.0")
+	return dir
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Jane Doe", "GIT_AUTHOR_EMAIL=jane@example.com", "GIT_AUTHOR_DATE=1700000000 +0100",
+		"GIT_COMMITTER_NAME=CI", "GIT_COMMITTER_EMAIL=ci@example.com", "GIT_COMMITTER_DATE=1700003600 -0500",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// packIndex returns the path of the only pack index in the repository
+func packIndex(t *testing.T, dir string) string {
+	t.Helper()
+	idxs, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+	if len(idxs) != 1 {
+		t.Fatalf("Expected one pack, got %v", idxs)
+	}
+	return idxs[0]
+}
+
+// chdir changes to dir until the test ends
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	orig, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(orig) })
+}
+
+func TestGit(t *testing.T) {
+	dir := gitFixture(t)
+	head := gitRun(t, dir, "rev-parse", "HEAD")
+	first := gitRun(t, dir, "rev-parse", "HEAD~1")
+	tree := gitRun(t, dir, "rev-parse", "HEAD^{tree}")
+
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+	commit := root.Func("git.commit").(func(string) (interface{}, error))
+
+	// Run the same checks against loose objects and refs, then again once
+	// gc has moved them into a packfile and packed-refs
+	check := func(t *testing.T) {
+		chdir(t, filepath.Join(dir, "modules"))
+
+		// Test that head describes the HEAD commit
+		t.Run("Head", func(t *testing.T) {
+			v, err := root.Get("git.head")
+			if err != nil {
+				t.Fatalf("git.head should not return error: %v", err)
+			}
+			c := v.(*gitCommit)
+			if c.SHA != head || c.Tree != tree || !reflect.DeepEqual(c.Parents, []string{first}) {
+				t.Errorf("Unexpected commit %+v", c)
+			}
+			if c.Message != "Second commit\n\nWith a body.\n" {
+				t.Errorf("Unexpected message %q", c.Message)
+			}
+			wantAuthor := &gitSignature{Name: "Jane Doe", Email: "jane@example.com", Time: "2023-11-14T23:13:20+01:00"}
+			if !reflect.DeepEqual(c.Author, wantAuthor) {
+				t.Errorf("Expected author %+v, got %+v", wantAuthor, c.Author)
+			}
+			if c.Committer.Time != "2023-11-14T18:13:20-05:00" {
+				t.Errorf("Unexpected committer %+v", c.Committer)
+			}
+		})
+
+		// Test that branch, tags_at_head and remote_urls are read
+		t.Run("Refs", func(t *testing.T) {
+			if v, _ := root.Get("git.branch"); v != "main" {
+				t.Errorf("Expected branch main, got %v", v)
+			}
+			v, err := root.Get("git.tags_at_head")
+			if err != nil {
+				t.Fatalf("git.tags_at_head should not return error: %v", err)
+			}
+			if want := []string{"latest", "v2.0"}; !reflect.DeepEqual(v, want) {
+				t.Errorf("Expected tags %v, got %v", want, v)
+			}
+			v, _ = root.Get("git.remote_urls")
+			want := map[string]string{"origin": "https://example.com/org/repo.git", "upstream": "git@example.com:org/repo.git"}
+			if !reflect.DeepEqual(v, want) {
+				t.Errorf("Expected remotes %v, got %v", want, v)
+			}
+		})
+
+		// Test that commit resolves SHAs, abbreviations, branches and tags
+		t.Run("Commit", func(t *testing.T) {
+			for rev, want := range map[string]string{
+				first:       first,
+				first[:7]:   first,
+				"HEAD":      head,
+				"main":      head,
+				"v1.0":      first,
+				"tags/v2.0": head,
+			} {
+				v, err := commit(rev)
+				if err != nil {
+					t.Errorf("%s: should not return error: %v", rev, err)
+					continue
+				}
+				if c, ok := v.(*gitCommit); !ok || c.SHA != want {
+					t.Errorf("%s: expected commit %s, got %+v", rev, want, v)
+				}
+			}
+
+			for _, rev := range []string{"nope", "0000000000000000000000000000000000000000", "config", "../HEAD"} {
+				if v, err := commit(rev); v != nil || err != nil {
+					t.Errorf("%s: expected undefined, got %v, %v", rev, v, err)
+				}
+			}
+
+			_, err := commit(tree)
+			if errorKind(err) != kindInvalidArgument {
+				t.Errorf("Expected invalid_argument for a tree, got %v", err)
+			}
+		})
+
+		// Test that pd.git reads as a namespace and as a whole map
+		t.Run("Namespace", func(t *testing.T) {
+			v, err := root.Get("git")
+			if err != nil {
+				t.Fatalf("git should not return error: %v", err)
+			}
+			ns := v.(*namespace)
+			if branch, _ := ns.Get("branch"); branch != "main" {
+				t.Errorf("Expected branch main, got %v", branch)
+			}
+			c, err := ns.Func("commit").(func(string) (interface{}, error))("v1.0")
+			if err != nil || c.(*gitCommit).SHA != first {
+				t.Errorf("Expected commit %s, got %+v, %v", first, c, err)
+			}
+
+			m, err := ns.Map()
+			if err != nil {
+				t.Fatalf("Map should not return error: %v", err)
+			}
+			keys := sortedKeys(m)
+			if want := []string{"branch", "head", "remote_urls", "tags_at_head"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("Expected keys %v, got %v", want, keys)
+			}
+		})
+	}
+
+	os.Mkdir(filepath.Join(dir, "modules"), 0755)
+	t.Run("Loose", check)
+
+	gitRun(t, dir, "gc", "-q", "--aggressive")
+	if _, err := os.Stat(filepath.Join(dir, ".git", "packed-refs")); err != nil {
+		t.Fatalf("gc did not pack refs: %v", err)
+	}
+	t.Run("Packed", check)
+
+	// Test that every object, including deltas, reads back as git sees it
+	t.Run("Objects", func(t *testing.T) {
+		if out := gitRun(t, dir, "verify-pack", "-v", packIndex(t, dir)); !strings.Contains(out, "chain length") {
+			t.Fatalf("Expected the pack to contain deltas:\n%s", out)
+		}
+		o, err := root.openGitObjects(filepath.Join(dir, ".git", "objects"))
+		if err != nil {
+			t.Fatalf("openGitObjects should not return error: %v", err)
+		}
+		for _, line := range strings.Split(gitRun(t, dir, "cat-file", "--batch-all-objects", "--batch-check"), "\n") {
+			fields := strings.Fields(line)
+			typ, data, err := o.read(fields[0])
+			if err != nil {
+				t.Errorf("%s: should not return error: %v", fields[0], err)
+				continue
+			}
+			want := gitRun(t, dir, "cat-file", fields[1], fields[0])
+			if typ != fields[1] || strings.TrimSpace(string(data)) != want {
+				t.Errorf("%s: expected %s %q, got %s %q", fields[0], fields[1], want, typ, data)
+			}
+		}
+	})
+
+	// Test that a pack index larger than max_file_bytes is still read, as
+	// lookups read only the parts of it they need
+	t.Run("LargeIndex", func(t *testing.T) {
+		info, err := os.Stat(packIndex(t, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		small := &Root{}
+		small.Configure(map[string]interface{}{"log_level": "off", "max_file_bytes": info.Size() / 2})
+		chdir(t, dir)
+		v, err := small.Get("git.head")
+		if c, ok := v.(*gitCommit); !ok || c.SHA != head {
+			t.Errorf("Expected head %s, got %+v, %v", head, v, err)
+		}
+		if v, err := small.Func("git.commit").(func(string) (interface{}, error))(first[:7]); err != nil || v.(*gitCommit).SHA != first {
+			t.Errorf("Expected commit %s, got %+v, %v", first, v, err)
+		}
+	})
+
+	// Test that an index whose fanout table disagrees with its contents is
+	// rejected rather than read out of bounds
+	t.Run("CorruptIndex", func(t *testing.T) {
+		idx, err := os.ReadFile(packIndex(t, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fanout := func(i int) []byte { return idx[8+i*4 : 8+i*4+4] }
+		count := binary.BigEndian.Uint32(fanout(255))
+		for name, corrupt := range map[string]func(b []byte){
+			"Unsorted": func(b []byte) { binary.BigEndian.PutUint32(b[8+10*4:], count+1) },
+			"TooMany":  func(b []byte) { binary.BigEndian.PutUint32(b[8+255*4:], count+1) },
+			"TooFew":   func(b []byte) { binary.BigEndian.PutUint32(b[8+255*4:], count-1) },
+			"Huge":     func(b []byte) { binary.BigEndian.PutUint32(b[8+255*4:], 0xffffffff) },
+		} {
+			data := append([]byte(nil), idx...)
+			corrupt(data)
+			path := filepath.Join(t.TempDir(), "pack-corrupt.idx")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := root.readGitPackIndex(path); errorKind(err) != kindDecode {
+				t.Errorf("%s: expected a decode error, got %v", name, err)
+			}
+		}
+	})
+
+	// Test that a detached HEAD has no branch but still has a head commit
+	t.Run("Detached", func(t *testing.T) {
+		gitRun(t, dir, "checkout", "-q", "--detach", "v1.0")
+		chdir(t, dir)
+		if v, err := root.Get("git.branch"); v != nil || err != nil {
+			t.Errorf("Expected undefined branch, got %v, %v", v, err)
+		}
+		v, _ := root.Get("git.head")
+		if c, ok := v.(*gitCommit); !ok || c.SHA != first {
+			t.Errorf("Expected head %s, got %+v", first, v)
+		}
+		if v, _ := root.Get("git.tags_at_head"); !reflect.DeepEqual(v, []string{"v1.0"}) {
+			t.Errorf("Expected tags [v1.0], got %v", v)
+		}
+	})
+
+	// Test that a linked worktree shares refs and objects with the main one
+	t.Run("Worktree", func(t *testing.T) {
+		wt := filepath.Join(t.TempDir(), "wt")
+		gitRun(t, dir, "worktree", "add", "-q", "-b", "feature", wt, "main")
+		chdir(t, wt)
+		if v, _ := root.Get("git.branch"); v != "feature" {
+			t.Errorf("Expected branch feature, got %v", v)
+		}
+		v, _ := root.Get("git.head")
+		if c, ok := v.(*gitCommit); !ok || c.SHA != head {
+			t.Errorf("Expected head %s, got %+v", head, v)
+		}
+	})
+
+	// Test that everything is undefined outside a repository
+	t.Run("NoRepository", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		for _, name := range []string{"git", "git.head", "git.branch", "git.tags_at_head", "git.remote_urls"} {
+			if v, err := root.Get(name); v != nil || err != nil {
+				t.Errorf("%s: expected undefined, got %v, %v", name, v, err)
+			}
+		}
+		if v, err := commit("HEAD"); v != nil || err != nil {
+			t.Errorf("Expected undefined commit, got %v, %v", v, err)
+		}
+	})
+}
+
+func TestApplyGitDelta(t *testing.T) {
+	base := []byte("hello, world")
+
+	// Test that copy and insert instructions rebuild the object
+	t.Run("Apply", func(t *testing.T) {
+		delta := []byte{12, 12, 0x91, 7, 5, 5, 'h', 'e', 'l', 'l', 'o', 0x91, 5, 2}
+		out, err := applyGitDelta(base, delta, 100)
+		if err != nil || string(out) != "worldhello, " {
+			t.Errorf("Expected %q, got %q, %v", "worldhello, ", out, err)
+		}
+	})
+
+	// Test that deltas reading past their base or with the wrong sizes fail
+	t.Run("Malformed", func(t *testing.T) {
+		for _, delta := range [][]byte{
+			{5, 5, 0x90, 5},
+			{12, 20, 0x91, 10, 5},
+			{12, 5, 0x90, 6},
+			{12, 1, 0},
+			{12, 200, 1},
+		} {
+			if _, err := applyGitDelta(base, delta, 100); err == nil {
+				t.Errorf("Expected an error for delta %v", delta)
+			}
+		}
+	})
+}
+
+// writeRefDeltaPack writes a pack and index to objects/pack under dir
+// holding one ref delta per entry of bases, each against the object
+// named by its value
+func writeRefDeltaPack(t *testing.T, dir string, bases map[string]string) {
+	t.Helper()
+	var ids [][]byte
+	for id := range bases {
+		raw, _ := hex.DecodeString(id)
+		ids = append(ids, raw)
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i], ids[j]) < 0 })
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, [2]uint32{2, uint32(len(ids))})
+	offsets := make([]uint32, len(ids))
+	for i, id := range ids {
+		offsets[i] = uint32(pack.Len())
+		delta := []byte{1, 1, 1, 'x'}
+		pack.WriteByte(gitObjRefDelta<<4 | byte(len(delta)))
+		base, _ := hex.DecodeString(bases[hex.EncodeToString(id)])
+		pack.Write(base)
+		zw := zlib.NewWriter(&pack)
+		zw.Write(delta)
+		zw.Close()
+	}
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+
+	var idx bytes.Buffer
+	idx.WriteString("\377tOc")
+	binary.Write(&idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		n := uint32(0)
+		for _, id := range ids {
+			if int(id[0]) <= b {
+				n++
+			}
+		}
+		binary.Write(&idx, binary.BigEndian, n)
+	}
+	for _, id := range ids {
+		idx.Write(id)
+	}
+	idx.Write(make([]byte, 4*len(ids))) // CRCs, not checked
+	binary.Write(&idx, binary.BigEndian, offsets)
+	idx.Write(sum[:])
+	idx.Write(make([]byte, 20))
+
+	packDir := filepath.Join(dir, "pack")
+	os.MkdirAll(packDir, 0755)
+	os.WriteFile(filepath.Join(packDir, "pack-test.pack"), pack.Bytes(), 0644)
+	os.WriteFile(filepath.Join(packDir, "pack-test.idx"), idx.Bytes(), 0644)
+}
+
+func TestGitRefDeltaCycle(t *testing.T) {
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+	a := strings.Repeat("a", 40)
+	b := strings.Repeat("b", 40)
+
+	// Test that ref deltas whose bases lead back to themselves stop at
+	// gitMaxDeltaDepth instead of recursing forever
+	for name, bases := range map[string]map[string]string{
+		"SelfReference": {a: a},
+		"Cycle":         {a: b, b: a},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRefDeltaPack(t, dir, bases)
+			o, err := root.openGitObjects(dir)
+			if err != nil {
+				t.Fatalf("openGitObjects should not return error: %v", err)
+			}
+			_, _, err = o.read(a)
+			if errorKind(err) != kindDecode || !strings.Contains(err.Error(), "delta chain too deep") {
+				t.Errorf("Expected delta chain too deep, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// Change types
+const (
+	gitAdded    = "added"
+	gitModified = "modified"
+	gitDeleted  = "deleted"
+	gitRenamed  = "renamed"
+)
+
+// The ID of the empty blob. Unrelated empty files share it, so it never
+// pairs a delete with an add as a rename.
+const gitEmptyBlob = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// Return structs
+type gitChange struct {
+	Path    string
+	OldPath string // the path in base, for renamed files
+	Change  string // added, modified, deleted or renamed
+	OldMode string // e.g. 100644, empty for added files
+	NewMode string // e.g. 100755, empty for deleted files
+
+	id string // contents of a deleted or added file, to detect renames
+}
+
+// gitTreeEntry is a file, symlink, submodule or subtree in a tree object
+type gitTreeEntry struct {
+	mode uint32
+	id   string
+}
+
+func (e gitTreeEntry) isTree() bool {
+	return e.mode&0170000 == 040000
+}
+
+// readTree parses a tree object: entries of "<mode> <name>\0" followed
+// by the 20 byte object ID
+func (g *gitRepo) readTree(id string) (map[string]gitTreeEntry, error) {
+	o, err := g.objectDB()
+	if err != nil {
+		return nil, err
+	}
+	typ, data, err := o.read(id)
+	if err != nil {
+		return nil, err
+	}
+	corrupt := func(msg string) error {
+		return &kindError{Kind: kindDecode, Path: g.common, Err: fmt.Errorf("tree %s: %s", id, msg)}
+	}
+	if typ != "tree" {
+		return nil, corrupt("is a " + typ)
+	}
+
+	entries := map[string]gitTreeEntry{}
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+21 {
+			return nil, corrupt("malformed entry")
+		}
+		mode, err := strconv.ParseUint(string(data[:sp]), 8, 32)
+		if err != nil {
+			return nil, corrupt("malformed mode")
+		}
+		entries[string(data[sp+1:nul])] = gitTreeEntry{mode: uint32(mode), id: hex.EncodeToString(data[nul+1 : nul+21])}
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// diffTrees appends the changes between two trees, either of which may
+// be "" for a tree that does not exist on that side. Subtrees with the
+// same ID are skipped without being read.
+func (g *gitRepo) diffTrees(dir, oldID, newID string, changes []*gitChange) ([]*gitChange, error) {
+	var oldEntries, newEntries map[string]gitTreeEntry
+	var err error
+	if oldID != "" {
+		if oldEntries, err = g.readTree(oldID); err != nil {
+			return nil, err
+		}
+	}
+	if newID != "" {
+		if newEntries, err = g.readTree(newID); err != nil {
+			return nil, err
+		}
+	}
+
+	names := map[string]bool{}
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		p := path.Join(dir, name)
+		o, inOld := oldEntries[name]
+		n, inNew := newEntries[name]
+		if inOld && inNew && o == n {
+			continue
+		}
+
+		// Recurse into trees on either side; a file replaced by a
+		// directory, or the other way around, is a delete and an add
+		oldTree, newTree := "", ""
+		if inOld && o.isTree() {
+			oldTree = o.id
+		}
+		if inNew && n.isTree() {
+			newTree = n.id
+		}
+		if oldTree != "" || newTree != "" {
+			if changes, err = g.diffTrees(p, oldTree, newTree, changes); err != nil {
+				return nil, err
+			}
+		}
+		oldFile := inOld && !o.isTree()
+		newFile := inNew && !n.isTree()
+
+		switch {
+		case oldFile && newFile:
+			changes = append(changes, &gitChange{Path: p, Change: gitModified, OldMode: gitMode(o), NewMode: gitMode(n)})
+		case oldFile:
+			changes = append(changes, &gitChange{Path: p, Change: gitDeleted, OldMode: gitMode(o), id: o.id})
+		case newFile:
+			changes = append(changes, &gitChange{Path: p, Change: gitAdded, NewMode: gitMode(n), id: n.id})
+		}
+	}
+	return changes, nil
+}
+
+func gitMode(e gitTreeEntry) string {
+	return fmt.Sprintf("%06o", e.mode)
+}
+
+// changedFiles diffs the trees of two commits. A file deleted in one
+// place and added with identical contents in another is reported as a
+// rename. Returns nil if either revision, or any tree between them, does
+// not exist, as in a shallow or partial clone.
+func (g *gitRepo) changedFiles(base, head string) ([]*gitChange, error) {
+	var trees [2]string
+	for i, rev := range []string{base, head} {
+		c, err := g.commit(rev)
+		if err != nil || c == nil {
+			return nil, err
+		}
+		trees[i] = c.Tree
+	}
+
+	changes, err := g.diffTrees("", trees[0], trees[1], nil)
+	if errors.Is(err, errGitNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return detectRenames(changes), nil
+}
+
+// detectRenames pairs each deleted file with an added file of identical
+// contents, in path order, and returns the changes sorted by path. Empty
+// files are never paired.
+func detectRenames(changes []*gitChange) []*gitChange {
+	added := map[string][]*gitChange{}
+	for _, c := range changes {
+		if c.Change == gitAdded && c.id != gitEmptyBlob {
+			added[c.id] = append(added[c.id], c)
+		}
+	}
+
+	renamed := map[*gitChange]bool{}
+	result := []*gitChange{}
+	for _, c := range changes {
+		if c.Change == gitDeleted && len(added[c.id]) > 0 {
+			to := added[c.id][0]
+			added[c.id] = added[c.id][1:]
+			renamed[to] = true
+			result = append(result, &gitChange{Path: to.Path, OldPath: c.Path, Change: gitRenamed, OldMode: c.OldMode, NewMode: to.NewMode})
+			continue
+		}
+		result = append(result, c)
+	}
+
+	changes = result[:0]
+	for _, c := range result {
+		if !renamed[c] {
+			changes = append(changes, c)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "git_changed_files",
+		Args:        []argSpec{{"base", "string"}, {"head", "string"}},
+		Returns:     "list(map)",
+		Description: "Files that differ between the trees of two commits, each given as for git.commit, read from the local object database. Each change has the path, added, modified, deleted or renamed, and the old and new modes. Undefined if either commit, or a tree between them, does not exist.",
+		Example:     `any pd.git_changed_files("origin/main", "HEAD") as c { c.path matches "^modules/network/" }`,
+		Shape:       []*gitChange(nil),
+		New: func(r *Root) interface{} {
+			return func(base, head string) (interface{}, error) {
+				return r.gitGet(func(g *gitRepo) (interface{}, error) {
+					return g.changedFiles(base, head)
+				})
+			}
+		},
+	})
+}
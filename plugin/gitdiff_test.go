@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitChangedFiles(t *testing.T) {
+	dir := gitFixture(t)
+	write := func(name, contents string, mode os.FileMode) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(contents), mode)
+		os.Chmod(path, mode)
+	}
+
+	write("modules/network/main.tf", "network", 0644)
+	write("modules/network/vars.tf", "variables", 0644)
+	write("scripts/run.sh", "#!/bin/sh", 0644)
+	write("old.txt", "moved around", 0644)
+	write("data", "a file, then a directory", 0644)
+	write("empty.txt", "", 0644)
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "Base")
+	base := gitRun(t, dir, "rev-parse", "HEAD")
+
+	write("main.tf", "updated", 0644)
+	os.Chmod(filepath.Join(dir, "scripts/run.sh"), 0755)
+	os.Remove(filepath.Join(dir, "modules/network/vars.tf"))
+	os.Remove(filepath.Join(dir, "old.txt"))
+	write("docs/new.txt", "moved around", 0644)
+	write("modules/compute/main.tf", "compute", 0644)
+	os.Remove(filepath.Join(dir, "data"))
+	write("data/a.txt", "a", 0644)
+	os.Remove(filepath.Join(dir, "empty.txt"))
+	write("modules/compute/.keep", "", 0644)
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "Head")
+
+	chdir(t, dir)
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+	changed := root.Func("git_changed_files").(func(string, string) (interface{}, error))
+
+	// Test that additions, deletions, modifications, renames and mode
+	// changes are reported in path order, without pairing empty files
+	// as renames
+	t.Run("Changes", func(t *testing.T) {
+		v, err := changed(base, "HEAD")
+		if err != nil {
+			t.Fatalf("git_changed_files should not return error: %v", err)
+		}
+		want := []*gitChange{
+			{Path: "data", Change: "deleted", OldMode: "100644"},
+			{Path: "data/a.txt", Change: "added", NewMode: "100644"},
+			{Path: "docs/new.txt", OldPath: "old.txt", Change: "renamed", OldMode: "100644", NewMode: "100644"},
+			{Path: "empty.txt", Change: "deleted", OldMode: "100644"},
+			{Path: "main.tf", Change: "modified", OldMode: "100644", NewMode: "100644"},
+			{Path: "modules/compute/.keep", Change: "added", NewMode: "100644"},
+			{Path: "modules/compute/main.tf", Change: "added", NewMode: "100644"},
+			{Path: "modules/network/vars.tf", Change: "deleted", OldMode: "100644"},
+			{Path: "scripts/run.sh", Change: "modified", OldMode: "100644", NewMode: "100755"},
+		}
+		got := v.([]*gitChange)
+		for _, c := range got {
+			c.id = ""
+		}
+		if !reflect.DeepEqual(got, want) {
+			for _, c := range got {
+				t.Logf("%+v", c)
+			}
+			t.Errorf("Unexpected changes")
+		}
+	})
+
+	// Test that the same commit on both sides has no changes
+	t.Run("NoChanges", func(t *testing.T) {
+		v, err := changed("HEAD", "main")
+		if err != nil || len(v.([]*gitChange)) != 0 {
+			t.Errorf("Expected no changes, got %v, %v", v, err)
+		}
+	})
+
+	// Test that an unknown revision is undefined
+	t.Run("UnknownRevision", func(t *testing.T) {
+		if v, err := changed("nope", "HEAD"); v != nil || err != nil {
+			t.Errorf("Expected undefined, got %v, %v", v, err)
+		}
+	})
+
+	// Test that a tree missing from a shallow or partial clone is
+	// undefined rather than an error
+	t.Run("MissingTree", func(t *testing.T) {
+		tree := gitRun(t, dir, "rev-parse", base+":modules/network")
+		if err := os.Remove(filepath.Join(dir, ".git", "objects", tree[:2], tree[2:])); err != nil {
+			t.Fatalf("Expected a loose tree object: %v", err)
+		}
+		if v, err := changed(base, "HEAD"); v != nil || err != nil {
+			t.Errorf("Expected undefined, got %v, %v", v, err)
+		}
+	})
+}
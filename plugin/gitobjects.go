@@ -0,0 +1,528 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Object types, as numbered in pack entry headers
+const (
+	gitObjCommit   = 1
+	gitObjTree     = 2
+	gitObjBlob     = 3
+	gitObjTag      = 4
+	gitObjOfsDelta = 6
+	gitObjRefDelta = 7
+)
+
+var gitTypeNames = map[int]string{
+	gitObjCommit: "commit",
+	gitObjTree:   "tree",
+	gitObjBlob:   "blob",
+	gitObjTag:    "tag",
+}
+
+// Deepest delta chain followed. git itself writes chains of at most 50
+// (250 with gc --aggressive); anything deeper is a corrupt or hostile pack.
+const gitMaxDeltaDepth = 1000
+
+// errGitNotFound is returned for objects that are not in the database,
+// which policies see as undefined: shallow clones lack older commits.
+var errGitNotFound = errors.New("object not found")
+
+// gitObjects reads the object database of a repository: loose objects
+// under objects/xx/ and packfiles under objects/pack/, through their
+// version 2 index. Only SHA-1 repositories are supported.
+type gitObjects struct {
+	r     *Root
+	dir   string
+	packs []*gitPack
+}
+
+// gitPack is a packfile and its index. Only the fanout table is kept in
+// memory; IDs and offsets are read from the index as they are needed, so
+// lookups cost the same however large the pack is.
+type gitPack struct {
+	path   string // the .pack
+	idx    string
+	fanout [256]uint32
+	large  int64 // entries in the table of 8 byte offsets
+}
+
+// Layout of a version 2 index: the header and fanout table, then for n
+// objects the sorted IDs, their CRCs, their 4 byte offsets and the large
+// offsets, and finally two 20 byte checksums
+const gitIdxHeaderSize = 8 + 256*4
+
+func (p *gitPack) count() int64 { return int64(p.fanout[255]) }
+
+func (r *Root) openGitObjects(dir string) (*gitObjects, error) {
+	o := &gitObjects{r: r, dir: dir}
+	idxs, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(idxs)
+	for _, idx := range idxs {
+		p, err := r.readGitPackIndex(idx)
+		if err != nil {
+			return nil, err
+		}
+		o.packs = append(o.packs, p)
+	}
+	return o, nil
+}
+
+// readGitPackIndex loads the header of a version 2 .idx file, see
+// gitformat-pack(5), and checks it against the size of the file
+func (r *Root) readGitPackIndex(path string) (*gitPack, error) {
+	corrupt := func(msg string) error {
+		return &kindError{Kind: kindDecode, Path: path, Err: errors.New(msg)}
+	}
+	p, err := withTimeout(r.conf().IOTimeout, path, func() (*gitPack, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		header := make([]byte, gitIdxHeaderSize)
+		if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header[:4], []byte("\377tOc")) || binary.BigEndian.Uint32(header[4:]) != 2 {
+			return nil, corrupt("not a version 2 pack index")
+		}
+		r.stats().addBytesRead(len(header))
+
+		p := &gitPack{path: strings.TrimSuffix(path, ".idx") + ".pack", idx: path}
+		for i := range p.fanout {
+			p.fanout[i] = binary.BigEndian.Uint32(header[8+i*4:])
+			if i > 0 && p.fanout[i] < p.fanout[i-1] {
+				return nil, corrupt("fanout table is not sorted")
+			}
+		}
+
+		// The size fixes the object count: 28 bytes per object, 8 per
+		// large offset and the checksums
+		n := p.count()
+		rest := info.Size() - gitIdxHeaderSize - n*(20+4+4) - 2*20
+		if rest < 0 || rest%8 != 0 || rest/8 > n {
+			return nil, corrupt(fmt.Sprintf("fanout table counts %d objects, which does not match the index size", n))
+		}
+		p.large = rest / 8
+		return p, nil
+	})
+	r.audit("file", path, err)
+	return p, err
+}
+
+// readIndex reads len(buf) bytes at off in p's index
+func (p *gitPack) readIndex(idx io.ReaderAt, buf []byte, off int64) error {
+	if _, err := idx.ReadAt(buf, off); err != nil {
+		return &kindError{Kind: kindDecode, Path: p.idx, Err: fmt.Errorf("truncated pack index: %s", err)}
+	}
+	return nil
+}
+
+// id returns the i-th object ID in p
+func (p *gitPack) id(idx io.ReaderAt, i int64) ([]byte, error) {
+	id := make([]byte, 20)
+	return id, p.readIndex(idx, id, gitIdxHeaderSize+i*20)
+}
+
+// search returns the position of the first ID in [lo, hi) not less than
+// id
+func (p *gitPack) search(idx io.ReaderAt, lo, hi int64, id []byte) (int64, error) {
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		got, err := p.id(idx, mid)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Compare(got, id) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// find returns the offset of id in the pack
+func (p *gitPack) find(idx io.ReaderAt, id []byte) (int64, bool, error) {
+	lo := int64(0)
+	if id[0] > 0 {
+		lo = int64(p.fanout[id[0]-1])
+	}
+	hi := int64(p.fanout[id[0]])
+	i, err := p.search(idx, lo, hi, id)
+	if err != nil || i >= hi {
+		return 0, false, err
+	}
+	if got, err := p.id(idx, i); err != nil || !bytes.Equal(got, id) {
+		return 0, false, err
+	}
+	return p.offset(idx, i)
+}
+
+func (p *gitPack) offset(idx io.ReaderAt, i int64) (int64, bool, error) {
+	n := p.count()
+	buf := make([]byte, 8)
+	if err := p.readIndex(idx, buf[:4], gitIdxHeaderSize+n*24+i*4); err != nil {
+		return 0, false, err
+	}
+	off := binary.BigEndian.Uint32(buf)
+	if off&0x80000000 == 0 {
+		return int64(off), true, nil
+	}
+	j := int64(off & 0x7fffffff)
+	if j >= p.large {
+		return 0, false, nil
+	}
+	if err := p.readIndex(idx, buf, gitIdxHeaderSize+n*28+j*8); err != nil {
+		return 0, false, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), true, nil
+}
+
+// withIndex opens p's index for fn, bounded by io_timeout
+func (o *gitObjects) withIndex(p *gitPack, fn func(idx io.ReaderAt) error) error {
+	_, err := withTimeout(o.r.conf().IOTimeout, p.idx, func() (struct{}, error) {
+		f, err := os.Open(p.idx)
+		if err != nil {
+			return struct{}{}, err
+		}
+		defer f.Close()
+		return struct{}{}, fn(f)
+	})
+	o.r.audit("file", p.idx, err)
+	return err
+}
+
+// read returns the type and contents of the object id
+func (o *gitObjects) read(id string) (string, []byte, error) {
+	return o.readObject(id, 0)
+}
+
+// readObject reads the object id as the base of a delta chain depth
+// entries deep, so ref deltas count towards gitMaxDeltaDepth like offset
+// deltas do
+func (o *gitObjects) readObject(id string, depth int) (string, []byte, error) {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != 20 {
+		return "", nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("invalid object ID %q", id)}
+	}
+
+	typ, data, err := o.readLoose(id)
+	if !errors.Is(err, errGitNotFound) {
+		return typ, data, err
+	}
+	for _, p := range o.packs {
+		var off int64
+		var ok bool
+		if err := o.withIndex(p, func(idx io.ReaderAt) (err error) {
+			off, ok, err = p.find(idx, raw)
+			return err
+		}); err != nil {
+			return "", nil, err
+		}
+		if ok {
+			// A base read mid-chain is already under readPacked's timeout
+			var t int
+			if depth == 0 {
+				t, data, err = o.readPacked(p, off)
+			} else {
+				t, data, err = o.readPackEntry(p, off, depth)
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			return gitTypeNames[t], data, nil
+		}
+	}
+	return "", nil, errGitNotFound
+}
+
+// readLoose reads a zlib-compressed "<type> <size>\0<contents>" file
+func (o *gitObjects) readLoose(id string) (string, []byte, error) {
+	path := filepath.Join(o.dir, id[:2], id[2:])
+	compressed, err := o.r.readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, errGitNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", nil, &kindError{Kind: kindDecode, Path: path, Err: err}
+	}
+	data, err := readLimited(zr, o.r.conf().MaxFileBytes, path)
+	if err != nil {
+		if errorKind(err) == "" {
+			err = &kindError{Kind: kindDecode, Path: path, Err: err}
+		}
+		return "", nil, err
+	}
+
+	header, contents, ok := bytes.Cut(data, []byte{0})
+	typ, size, _ := strings.Cut(string(header), " ")
+	if !ok || fmt.Sprint(len(contents)) != size {
+		return "", nil, &kindError{Kind: kindDecode, Path: path, Err: errors.New("malformed object header")}
+	}
+	return typ, contents, nil
+}
+
+// readPacked reads the entry at off in p, bounded by io_timeout like any
+// other file read
+func (o *gitObjects) readPacked(p *gitPack, off int64) (int, []byte, error) {
+	type entry struct {
+		typ  int
+		data []byte
+	}
+	e, err := withTimeout(o.r.conf().IOTimeout, p.path, func() (*entry, error) {
+		typ, data, err := o.readPackEntry(p, off, 0)
+		return &entry{typ, data}, err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return e.typ, e.data, nil
+}
+
+// readPackEntry reads the entry at off in p, resolving deltas against
+// their base objects
+func (o *gitObjects) readPackEntry(p *gitPack, off int64, depth int) (int, []byte, error) {
+	corrupt := func(err error) error {
+		return &kindError{Kind: kindDecode, Path: p.path, Err: fmt.Errorf("entry at offset %d: %s", off, err)}
+	}
+	if depth > gitMaxDeltaDepth {
+		return 0, nil, corrupt(errors.New("delta chain too deep"))
+	}
+
+	f, err := os.Open(p.path)
+	o.r.audit("file", p.path, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(io.NewSectionReader(f, off, 1<<62))
+
+	// Type and inflated size: 3 bits of type and 4 of size in the first
+	// byte, then 7 more bits of size per byte while the high bit is set
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, corrupt(err)
+	}
+	typ := int(b>>4) & 7
+	size := uint64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = br.ReadByte(); err != nil || shift > 56 {
+			return 0, nil, corrupt(errors.New("malformed entry header"))
+		}
+		size |= uint64(b&0x7f) << shift
+	}
+
+	var baseType int
+	var base []byte
+	switch typ {
+	case gitObjCommit, gitObjTree, gitObjBlob, gitObjTag:
+	case gitObjOfsDelta:
+		// Offset back to the base, big-endian with an implicit +1 added
+		// to every continuation byte
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, corrupt(err)
+		}
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = br.ReadByte(); err != nil || rel > 1<<55 {
+				return 0, nil, corrupt(errors.New("malformed delta offset"))
+			}
+			rel = (rel+1)<<7 | int64(b&0x7f)
+		}
+		if rel <= 0 || rel > off {
+			return 0, nil, corrupt(errors.New("delta base out of range"))
+		}
+		if baseType, base, err = o.readPackEntry(p, off-rel, depth+1); err != nil {
+			return 0, nil, err
+		}
+	case gitObjRefDelta:
+		id := make([]byte, 20)
+		if _, err := io.ReadFull(br, id); err != nil {
+			return 0, nil, corrupt(err)
+		}
+		t, data, err := o.readObject(hex.EncodeToString(id), depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseType, base = gitTypeNumber(t), data
+	default:
+		return 0, nil, corrupt(fmt.Errorf("unknown object type %d", typ))
+	}
+
+	limit := o.r.conf().MaxFileBytes
+	if size > uint64(limit) {
+		return 0, nil, &kindError{Kind: kindTooLarge, Path: p.path, Err: fmt.Errorf("object exceeds max_file_bytes (%d)", limit)}
+	}
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, corrupt(err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, corrupt(err)
+	}
+	o.r.stats().addBytesRead(len(data))
+
+	if base == nil {
+		return typ, data, nil
+	}
+	data, err = applyGitDelta(base, data, limit)
+	if err != nil {
+		return 0, nil, corrupt(err)
+	}
+	return baseType, data, nil
+}
+
+func gitTypeNumber(name string) int {
+	for n, s := range gitTypeNames {
+		if s == name {
+			return n
+		}
+	}
+	return 0
+}
+
+// applyGitDelta rebuilds an object from its base and a delta: the base
+// and result sizes, then instructions that either copy a range of the
+// base or insert literal bytes
+func applyGitDelta(base, delta []byte, limit int64) ([]byte, error) {
+	varint := func() (uint64, bool) {
+		var n uint64
+		for shift := 0; len(delta) > 0 && shift < 64; shift += 7 {
+			b := delta[0]
+			delta = delta[1:]
+			n |= uint64(b&0x7f) << shift
+			if b&0x80 == 0 {
+				return n, true
+			}
+		}
+		return 0, false
+	}
+
+	srcSize, ok1 := varint()
+	dstSize, ok2 := varint()
+	if !ok1 || !ok2 || srcSize != uint64(len(base)) {
+		return nil, errors.New("delta does not match its base")
+	}
+	if dstSize > uint64(limit) {
+		return nil, fmt.Errorf("object exceeds max_file_bytes (%d)", limit)
+	}
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Copy: bits 0-3 say which offset bytes follow, bits 4-6
+			// which size bytes
+			var off, n uint64
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("truncated delta")
+				}
+				if i < 4 {
+					off |= uint64(delta[0]) << (8 * i)
+				} else {
+					n |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if off+n > uint64(len(base)) {
+				return nil, errors.New("delta copies past the end of its base")
+			}
+			out = append(out, base[off:off+n]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("reserved delta instruction")
+		}
+		if uint64(len(out)) > dstSize {
+			return nil, errors.New("delta result larger than declared")
+		}
+	}
+	if uint64(len(out)) != dstSize {
+		return nil, errors.New("delta result smaller than declared")
+	}
+	return out, nil
+}
+
+// expand returns the IDs of every object starting with the hex prefix,
+// for abbreviated revisions
+func (o *gitObjects) expand(prefix string) ([]string, error) {
+	found := map[string]bool{}
+
+	entries, err := os.ReadDir(filepath.Join(o.dir, prefix[:2]))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if id := prefix[:2] + e.Name(); len(id) == 40 && strings.HasPrefix(id, prefix) {
+			found[id] = true
+		}
+	}
+
+	// The prefix padded with zeros sorts before every ID it starts
+	start, _ := hex.DecodeString((prefix + strings.Repeat("0", 40))[:40])
+	for _, p := range o.packs {
+		var ids []string
+		err := o.withIndex(p, func(idx io.ReaderAt) error {
+			i, err := p.search(idx, 0, p.count(), start)
+			for ; err == nil && i < p.count(); i++ {
+				var raw []byte
+				if raw, err = p.id(idx, i); err != nil {
+					break
+				}
+				id := hex.EncodeToString(raw)
+				if !strings.HasPrefix(id, prefix) {
+					break
+				}
+				ids = append(ids, id)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			found[id] = true
+		}
+	}
+	return sortedKeys(found), nil
+}
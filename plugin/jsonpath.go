@@ -0,0 +1,963 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonPath is a parsed RFC 9535 JSONPath query. It is evaluated against
+// documents normalized by queryValue: maps with string keys, slices,
+// strings, float64 numbers, bools and nil.
+type jsonPath struct {
+	segments []*jsonPathSegment
+}
+
+// jsonPathSegment is a child segment, [...] or .name, or a descendant
+// segment, ..[...] or ..name
+type jsonPathSegment struct {
+	descendant bool
+	selectors  []*jsonPathSelector
+}
+
+const (
+	selectName = iota
+	selectWildcard
+	selectIndex
+	selectSlice
+	selectFilter
+)
+
+type jsonPathSelector struct {
+	kind   int
+	name   string
+	index  int64
+	slice  [3]*int64 // start, end and step, nil when omitted
+	filter *jsonPathExpr
+}
+
+// jsonPathExpr is a node of a filter expression. op is a logical or
+// comparison operator, "literal", "query" or "function".
+type jsonPathExpr struct {
+	op       string
+	args     []*jsonPathExpr
+	literal  interface{}
+	query    *jsonPath
+	relative bool // the query starts at @ rather than $
+	function string
+}
+
+// jsonPathNothing is the absence of a value, such as the result of a
+// singular query that selects no node
+type jsonPathNothing struct{}
+
+// Largest magnitude of an integer in a JSONPath query, as in I-JSON
+const jsonPathMaxInt = 1<<53 - 1
+
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+func parseJSONPath(s string) (*jsonPath, error) {
+	p := &jsonPathParser{src: s}
+	if !p.consume("$") {
+		return nil, p.errorf("must start with $")
+	}
+	path, err := p.segments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return path, nil
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return &kindError{
+		Kind: kindInvalidArgument,
+		Err:  fmt.Errorf("invalid JSONPath %q at offset %d: %s", p.src, p.pos, fmt.Sprintf(format, args...)),
+	}
+}
+
+func (p *jsonPathParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// segments parses the segments following $ or @. Whitespace may
+// separate segments, so it is skipped only when a segment follows.
+func (p *jsonPathParser) segments() (*jsonPath, error) {
+	path := &jsonPath{}
+	for {
+		start := p.pos
+		p.skipSpace()
+		var seg *jsonPathSegment
+		var err error
+		switch {
+		case p.consume(".."):
+			if p.peek("[") {
+				seg, err = p.bracketed()
+			} else {
+				seg, err = p.shorthand()
+			}
+			if seg != nil {
+				seg.descendant = true
+			}
+		case p.consume("."):
+			seg, err = p.shorthand()
+		case p.peek("["):
+			seg, err = p.bracketed()
+		default:
+			p.pos = start
+			return path, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		path.segments = append(path.segments, seg)
+	}
+}
+
+// shorthand parses the * or member name following . or ..
+func (p *jsonPathParser) shorthand() (*jsonPathSegment, error) {
+	if p.consume("*") {
+		return &jsonPathSegment{selectors: []*jsonPathSelector{{kind: selectWildcard}}}, nil
+	}
+	name := p.memberName()
+	if name == "" {
+		return nil, p.errorf("expected a member name")
+	}
+	return &jsonPathSegment{selectors: []*jsonPathSelector{{kind: selectName, name: name}}}, nil
+}
+
+// memberName parses a shorthand name: a letter, underscore or non-ASCII
+// character, followed by those or digits
+func (p *jsonPathParser) memberName() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !(r == '_' || r >= 0x80 || (r|0x20 >= 'a' && r|0x20 <= 'z') || (p.pos > start && r >= '0' && r <= '9')) {
+			break
+		}
+		p.pos += size
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *jsonPathParser) bracketed() (*jsonPathSegment, error) {
+	p.consume("[")
+	seg := &jsonPathSegment{}
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return seg, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *jsonPathParser) selector() (*jsonPathSelector, error) {
+	switch {
+	case p.consume("*"):
+		return &jsonPathSelector{kind: selectWildcard}, nil
+	case p.peek("'") || p.peek(`"`):
+		name, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathSelector{kind: selectName, name: name}, nil
+	case p.consume("?"):
+		p.skipSpace()
+		expr, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathSelector{kind: selectFilter, filter: expr}, nil
+	}
+
+	// An index, or a slice when a colon follows
+	var bounds [3]*int64
+	for i := range bounds {
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+		p.skipSpace()
+		if i == 0 && !p.peek(":") {
+			if n == nil {
+				return nil, p.errorf("expected a selector")
+			}
+			return &jsonPathSelector{kind: selectIndex, index: *n}, nil
+		}
+		if i == 2 || !p.consume(":") {
+			break
+		}
+		p.skipSpace()
+	}
+	return &jsonPathSelector{kind: selectSlice, slice: bounds}, nil
+}
+
+// integer parses an optional integer, returning nil if there is none
+func (p *jsonPathParser) integer() (*int64, error) {
+	if p.pos >= len(p.src) || (p.src[p.pos] != '-' && (p.src[p.pos] < '0' || p.src[p.pos] > '9')) {
+		return nil, nil
+	}
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if p.pos == digits || (p.src[digits] == '0' && (p.pos > digits+1 || digits > start)) {
+		return nil, p.errorf("invalid integer %q", s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > jsonPathMaxInt || n < -jsonPathMaxInt {
+		return nil, p.errorf("integer %s out of range", s)
+	}
+	return &n, nil
+}
+
+// stringLiteral parses a single or double quoted string with JSON escapes
+func (p *jsonPathParser) stringLiteral() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+		if p.pos >= len(p.src) {
+			break
+		}
+		e := p.src[p.pos]
+		p.pos++
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(e)
+		case '\'', '"':
+			if e != quote {
+				return "", p.errorf("invalid escape \\%c", e)
+			}
+			b.WriteByte(e)
+		case 'u':
+			r, err := p.unicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			return "", p.errorf("invalid escape \\%c", e)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// unicodeEscape parses the hex digits of \uXXXX, and a second escape
+// when the first is a high surrogate
+func (p *jsonPathParser) unicodeEscape() (rune, error) {
+	hex := func() (rune, error) {
+		if p.pos+4 > len(p.src) {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		p.pos += 4
+		return rune(n), nil
+	}
+	r, err := hex()
+	if err != nil || r < 0xD800 || r > 0xDFFF {
+		return r, err
+	}
+	if r > 0xDBFF || !p.consume(`\u`) {
+		return 0, p.errorf("unpaired surrogate")
+	}
+	low, err := hex()
+	if err != nil {
+		return 0, err
+	}
+	if low < 0xDC00 || low > 0xDFFF {
+		return 0, p.errorf("unpaired surrogate")
+	}
+	return (r-0xD800)<<10 + (low - 0xDC00) + 0x10000, nil
+}
+
+func (p *jsonPathParser) logicalOr() (*jsonPathExpr, error) {
+	return p.binary("||", p.logicalAnd)
+}
+
+func (p *jsonPathParser) logicalAnd() (*jsonPathExpr, error) {
+	return p.binary("&&", p.basic)
+}
+
+func (p *jsonPathParser) binary(op string, operand func() (*jsonPathExpr, error)) (*jsonPathExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume(op) {
+			return left, nil
+		}
+		p.skipSpace()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonPathExpr{op: op, args: []*jsonPathExpr{left, right}}
+	}
+}
+
+// basic parses a parenthesized or negated expression, a comparison or a
+// test of a query or function
+func (p *jsonPathParser) basic() (*jsonPathExpr, error) {
+	if p.consume("!") {
+		p.skipSpace()
+		expr, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		if expr.op != "(" && expr.op != "query" && expr.op != "function" {
+			return nil, p.errorf("! must be followed by a test or parentheses")
+		}
+		return &jsonPathExpr{op: "!", args: []*jsonPathExpr{expr}}, nil
+	}
+	if p.consume("(") {
+		p.skipSpace()
+		expr, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return &jsonPathExpr{op: "(", args: []*jsonPathExpr{expr}}, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range []*jsonPathExpr{left, right} {
+			if err := p.checkComparable(e); err != nil {
+				return nil, err
+			}
+		}
+		return &jsonPathExpr{op: op, args: []*jsonPathExpr{left, right}}, nil
+	}
+
+	switch {
+	case left.op == "literal":
+		return nil, p.errorf("a literal must be compared")
+	case left.op == "function" && left.function != "match" && left.function != "search":
+		return nil, p.errorf("%s() must be compared", left.function)
+	}
+	return left, nil
+}
+
+// checkComparable rejects comparisons of queries that may select more
+// than one node, and of functions that do not return a value
+func (p *jsonPathParser) checkComparable(e *jsonPathExpr) error {
+	switch {
+	case e.op == "query" && !e.query.singular():
+		return p.errorf("only singular queries can be compared")
+	case e.op == "function" && (e.function == "match" || e.function == "search"):
+		return p.errorf("%s() cannot be compared", e.function)
+	}
+	return nil
+}
+
+// operand parses a literal, a query or a function call
+func (p *jsonPathParser) operand() (*jsonPathExpr, error) {
+	switch {
+	case p.peek("@") || p.peek("$"):
+		relative := p.src[p.pos] == '@'
+		p.pos++
+		q, err := p.segments()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathExpr{op: "query", query: q, relative: relative}, nil
+	case p.peek("'") || p.peek(`"`):
+		s, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonPathExpr{op: "literal", literal: s}, nil
+	case p.consume("true"):
+		return &jsonPathExpr{op: "literal", literal: true}, nil
+	case p.consume("false"):
+		return &jsonPathExpr{op: "literal", literal: false}, nil
+	case p.consume("null"):
+		return &jsonPathExpr{op: "literal", literal: nil}, nil
+	}
+
+	if p.pos < len(p.src) && (p.src[p.pos] == '-' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+		start := p.pos
+		for p.pos < len(p.src) && strings.IndexByte("+-.0123456789eE", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		s := p.src[start:p.pos]
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || !jsonPathNumber.MatchString(s) {
+			return nil, p.errorf("invalid number %q", s)
+		}
+		return &jsonPathExpr{op: "literal", literal: n}, nil
+	}
+
+	// A function name is a lowercase letter, then lowercase letters,
+	// digits and underscores
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.pos > start && (p.src[p.pos] == '_' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" || !p.consume("(") {
+		p.pos = start
+		return nil, p.errorf("expected a literal, query or function")
+	}
+	return p.function(name)
+}
+
+// jsonPathNumber is the JSON number grammar, which a literal must follow
+// although ParseFloat accepts more, such as "01" or "1."
+var jsonPathNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// jsonPathFunctions gives the number of arguments of each function
+var jsonPathFunctions = map[string]int{"length": 1, "count": 1, "match": 2, "search": 2, "value": 1}
+
+func (p *jsonPathParser) function(name string) (*jsonPathExpr, error) {
+	arity, ok := jsonPathFunctions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %s()", name)
+	}
+	expr := &jsonPathExpr{op: "function", function: name}
+	p.skipSpace()
+	for !p.consume(")") {
+		if len(expr.args) > 0 && !p.consume(",") {
+			return nil, p.errorf("expected , or )")
+		}
+		p.skipSpace()
+		arg, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		expr.args = append(expr.args, arg)
+		p.skipSpace()
+	}
+	if len(expr.args) != arity {
+		return nil, p.errorf("%s() takes %d arguments", name, arity)
+	}
+
+	// count and value take any query; the others take values
+	for _, arg := range expr.args {
+		if name == "count" || name == "value" {
+			if arg.op != "query" {
+				return nil, p.errorf("%s() takes a query", name)
+			}
+		} else if err := p.checkComparable(arg); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// singular reports whether the query selects at most one node
+func (q *jsonPath) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		if k := seg.selectors[0].kind; k != selectName && k != selectIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// eval returns the values of the nodes selected from v
+func (q *jsonPath) eval(root, v interface{}) []interface{} {
+	nodes := []interface{}{v}
+	for _, seg := range q.segments {
+		var next []interface{}
+		for _, n := range nodes {
+			if seg.descendant {
+				jsonPathDescend(n, func(d interface{}) {
+					next = seg.apply(root, d, next)
+				})
+			} else {
+				next = seg.apply(root, n, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// jsonPathDescend calls fn for v and each of its descendants, parents
+// before children
+func jsonPathDescend(v interface{}, fn func(interface{})) {
+	fn(v)
+	for _, child := range jsonPathChildren(v) {
+		jsonPathDescend(child, fn)
+	}
+}
+
+// jsonPathChildren returns the elements of an array, or the values of an
+// object in key order
+func jsonPathChildren(v interface{}) []interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		return x
+	case map[string]interface{}:
+		children := make([]interface{}, 0, len(x))
+		for _, k := range sortedKeys(x) {
+			children = append(children, x[k])
+		}
+		return children
+	}
+	return nil
+}
+
+func (seg *jsonPathSegment) apply(root, v interface{}, out []interface{}) []interface{} {
+	for _, sel := range seg.selectors {
+		switch sel.kind {
+		case selectName:
+			if m, ok := v.(map[string]interface{}); ok {
+				if child, ok := m[sel.name]; ok {
+					out = append(out, child)
+				}
+			}
+		case selectWildcard:
+			out = append(out, jsonPathChildren(v)...)
+		case selectIndex:
+			if a, ok := v.([]interface{}); ok {
+				i := sel.index
+				if i < 0 {
+					i += int64(len(a))
+				}
+				if i >= 0 && i < int64(len(a)) {
+					out = append(out, a[i])
+				}
+			}
+		case selectSlice:
+			if a, ok := v.([]interface{}); ok {
+				out = jsonPathSlice(a, sel.slice, out)
+			}
+		case selectFilter:
+			for _, child := range jsonPathChildren(v) {
+				if sel.filter.test(root, child) {
+					out = append(out, child)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// jsonPathSlice appends a[start:end:step] as RFC 9535 defines it, with
+// negative bounds counting from the end and a negative step going
+// backwards
+func jsonPathSlice(a []interface{}, bounds [3]*int64, out []interface{}) []interface{} {
+	n := int64(len(a))
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return out
+	}
+	bound := func(b *int64, def int64) int64 {
+		if b == nil {
+			return def
+		}
+		if *b < 0 {
+			return *b + n
+		}
+		return *b
+	}
+	clamp := func(i, lo, hi int64) int64 {
+		return min(max(i, lo), hi)
+	}
+
+	if step > 0 {
+		lower, upper := clamp(bound(bounds[0], 0), 0, n), clamp(bound(bounds[1], n), 0, n)
+		for i := lower; i < upper; i += step {
+			out = append(out, a[i])
+		}
+	} else {
+		upper, lower := clamp(bound(bounds[0], n-1), -1, n-1), clamp(bound(bounds[1], -n-1), -1, n-1)
+		for i := upper; i > lower; i += step {
+			out = append(out, a[i])
+		}
+	}
+	return out
+}
+
+// test evaluates a logical expression against the current node
+func (e *jsonPathExpr) test(root, current interface{}) bool {
+	switch e.op {
+	case "||":
+		return e.args[0].test(root, current) || e.args[1].test(root, current)
+	case "&&":
+		return e.args[0].test(root, current) && e.args[1].test(root, current)
+	case "!":
+		return !e.args[0].test(root, current)
+	case "(":
+		return e.args[0].test(root, current)
+	case "query":
+		return len(e.nodes(root, current)) > 0
+	case "function":
+		return e.value(root, current) == true
+	}
+
+	a, b := e.args[0].value(root, current), e.args[1].value(root, current)
+	switch e.op {
+	case "==":
+		return jsonPathEqual(a, b)
+	case "!=":
+		return !jsonPathEqual(a, b)
+	case "<":
+		return jsonPathLess(a, b)
+	case "<=":
+		return jsonPathLess(a, b) || jsonPathEqual(a, b)
+	case ">":
+		return jsonPathLess(b, a)
+	case ">=":
+		return jsonPathLess(b, a) || jsonPathEqual(a, b)
+	}
+	return false
+}
+
+func (e *jsonPathExpr) nodes(root, current interface{}) []interface{} {
+	if e.relative {
+		return e.query.eval(root, current)
+	}
+	return e.query.eval(root, root)
+}
+
+// value evaluates a literal, a singular query or a function, returning
+// jsonPathNothing when there is no value
+func (e *jsonPathExpr) value(root, current interface{}) interface{} {
+	switch e.op {
+	case "literal":
+		return e.literal
+	case "query":
+		nodes := e.nodes(root, current)
+		if len(nodes) != 1 {
+			return jsonPathNothing{}
+		}
+		return nodes[0]
+	}
+
+	switch e.function {
+	case "length":
+		switch x := e.args[0].value(root, current).(type) {
+		case string:
+			return float64(utf8.RuneCountInString(x))
+		case []interface{}:
+			return float64(len(x))
+		case map[string]interface{}:
+			return float64(len(x))
+		}
+		return jsonPathNothing{}
+	case "count":
+		return float64(len(e.args[0].nodes(root, current)))
+	case "value":
+		return e.args[0].value(root, current)
+	}
+
+	// match and search take an I-Regexp (RFC 9485). Anything that is not
+	// a string or a valid pattern is false.
+	s, ok := e.args[0].value(root, current).(string)
+	pattern, ok2 := e.args[1].value(root, current).(string)
+	if !ok || !ok2 {
+		return false
+	}
+	re, err := compileIRegexp(pattern, e.function == "match")
+	return err == nil && re.MatchString(s)
+}
+
+// compileIRegexp checks pattern against the I-Regexp grammar of RFC 9485
+// and translates it to RE2, which would otherwise accept far more: \d,
+// anchors, non-greedy and possessive quantifiers, groups with flags and
+// so on. In I-Regexp . matches anything but \n and \r, and ^ and $ are
+// ordinary characters. A match is anchored at both ends.
+func compileIRegexp(pattern string, anchored bool) (*regexp.Regexp, error) {
+	if !utf8.ValidString(pattern) {
+		return nil, fmt.Errorf("invalid UTF-8")
+	}
+	t := &iregexpTranslator{src: []rune(pattern)}
+	if err := t.branches(0); err != nil {
+		return nil, err
+	}
+	if t.pos < len(t.src) {
+		return nil, fmt.Errorf("unexpected %q", t.src[t.pos])
+	}
+	re := t.out.String()
+	if anchored {
+		re = `\A(?:` + re + `)\z`
+	}
+	return regexp.Compile(re)
+}
+
+type iregexpTranslator struct {
+	src []rune
+	pos int
+	out strings.Builder
+}
+
+func (t *iregexpTranslator) peek() rune {
+	if t.pos < len(t.src) {
+		return t.src[t.pos]
+	}
+	return -1
+}
+
+// branches parses branch *( "|" branch ), inside depth groups
+func (t *iregexpTranslator) branches(depth int) error {
+	for {
+		for t.peek() != -1 && t.peek() != '|' && t.peek() != ')' {
+			if err := t.piece(depth); err != nil {
+				return err
+			}
+		}
+		if t.peek() != '|' {
+			return nil
+		}
+		t.out.WriteRune('|')
+		t.pos++
+	}
+}
+
+// piece parses an atom and an optional quantifier
+func (t *iregexpTranslator) piece(depth int) error {
+	switch c := t.peek(); c {
+	case '(':
+		t.pos++
+		t.out.WriteString("(?:")
+		if err := t.branches(depth + 1); err != nil {
+			return err
+		}
+		if t.peek() != ')' {
+			return fmt.Errorf("missing )")
+		}
+		t.pos++
+		t.out.WriteRune(')')
+	case '.':
+		t.pos++
+		t.out.WriteString(`[^\n\r]`)
+	case '[':
+		if err := t.class(); err != nil {
+			return err
+		}
+	case '\\':
+		if err := t.escape(); err != nil {
+			return err
+		}
+	case '?', '*', '+', '{', '}', ']':
+		return fmt.Errorf("unexpected %q", c)
+	default:
+		t.pos++
+		t.out.WriteString(regexp.QuoteMeta(string(c)))
+	}
+
+	switch c := t.peek(); c {
+	case '?', '*', '+':
+		t.pos++
+		t.out.WriteRune(c)
+	case '{':
+		start := t.pos
+		for t.pos < len(t.src) && t.src[t.pos] != '}' {
+			t.pos++
+		}
+		if t.pos == len(t.src) {
+			return fmt.Errorf("unterminated quantifier")
+		}
+		t.pos++
+		q := string(t.src[start:t.pos])
+		if !iregexpRange.MatchString(q) {
+			return fmt.Errorf("invalid quantifier %s", q)
+		}
+		t.out.WriteString(q)
+	}
+	return nil
+}
+
+// iregexpRange is a quantifier {n}, {n,} or {n,m}
+var iregexpRange = regexp.MustCompile(`^\{[0-9]+(,[0-9]*)?\}$`)
+
+// class parses a character class expression: [, an optional ^, then
+// characters, ranges and escapes, with - allowed only first or last
+func (t *iregexpTranslator) class() error {
+	t.pos++
+	t.out.WriteRune('[')
+	if t.peek() == '^' {
+		t.pos++
+		t.out.WriteRune('^')
+	}
+	first := true
+	for {
+		c := t.peek()
+		switch {
+		case c == -1:
+			return fmt.Errorf("unterminated character class")
+		case c == ']' && !first:
+			t.pos++
+			t.out.WriteRune(']')
+			return nil
+		case c == '-' && (first || t.pos+1 < len(t.src) && t.src[t.pos+1] == ']'):
+			t.pos++
+			t.out.WriteString(`\-`)
+		case c == '\\' && t.pos+1 < len(t.src) && (t.src[t.pos+1] == 'p' || t.src[t.pos+1] == 'P'):
+			if err := t.escape(); err != nil {
+				return err
+			}
+		default:
+			if err := t.classChar(); err != nil {
+				return err
+			}
+			if t.peek() == '-' && t.pos+1 < len(t.src) && t.src[t.pos+1] != ']' {
+				t.pos++
+				t.out.WriteRune('-')
+				if err := t.classChar(); err != nil {
+					return err
+				}
+			}
+		}
+		first = false
+	}
+}
+
+// classChar parses a single character in a class, escaped or not
+func (t *iregexpTranslator) classChar() error {
+	switch c := t.peek(); c {
+	case '\\':
+		return t.escape()
+	case '[', ']', '-', -1:
+		return fmt.Errorf("unexpected %q in character class", c)
+	default:
+		t.pos++
+		t.out.WriteString(regexp.QuoteMeta(string(c)))
+		return nil
+	}
+}
+
+// escape parses a single character escape or a \p{...} or \P{...}
+// category escape
+func (t *iregexpTranslator) escape() error {
+	t.pos++
+	c := t.peek()
+	t.pos++
+	switch {
+	case c == 'n':
+		t.out.WriteString(`\n`)
+	case c == 'r':
+		t.out.WriteString(`\r`)
+	case c == 't':
+		t.out.WriteString(`\t`)
+	case c != -1 && strings.ContainsRune(`()*+-.?[\]^{|}`, c):
+		t.out.WriteString(`\` + string(c))
+	case c == 'p' || c == 'P':
+		start := t.pos
+		for t.pos < len(t.src) && t.src[t.pos] != '}' {
+			t.pos++
+		}
+		if t.pos == len(t.src) || !iregexpCategory.MatchString(string(t.src[start:t.pos+1])) {
+			return fmt.Errorf("invalid category escape")
+		}
+		t.pos++
+		t.out.WriteString(`\` + string(c) + string(t.src[start:t.pos]))
+	default:
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// iregexpCategory is the {...} of a \p or \P escape: a Unicode general
+// category, as RFC 9485 has no block escapes
+var iregexpCategory = regexp.MustCompile(`^\{(L[ultmo]?|M[nce]?|N[dlo]?|P[cdseifo]?|Z[slp]?|S[mcko]?|C[cfon]?)\}$`)
+
+// jsonPathEqual compares numbers by value and arrays and objects deeply;
+// two absent values are equal
+func jsonPathEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// jsonPathLess orders two numbers or two strings; any other pair is
+// unordered
+func jsonPathLess(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		return ok && x < y && !math.IsNaN(y)
+	case string:
+		y, ok := b.(string)
+		return ok && x < y
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	// The example document of RFC 9535, section 1.5
+	doc, err := decodeJSON([]byte(`{"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 399}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := queryValue(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authors := []interface{}{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}
+
+	// Test the selectors and segments of RFC 9535
+	t.Run("Selectors", func(t *testing.T) {
+		for expr, want := range map[string][]interface{}{
+			"$":                              {v},
+			"$.store.book[*].author":         authors,
+			"$..author":                      authors,
+			"$.store.bicycle.color":          {"red"},
+			`$["store"]['bicycle']["color"]`: {"red"},
+			"$.store.*.color":                {"red"},
+			"$..book[2].title":               {"Moby Dick"},
+			"$..book[-1].title":              {"The Lord of the Rings"},
+			"$..book[0,1].title":             {"Sayings of the Century", "Sword of Honour"},
+			"$..book[:2].title":              {"Sayings of the Century", "Sword of Honour"},
+			"$..book[1:4:2].title":           {"Sword of Honour", "The Lord of the Rings"},
+			"$..book[::-1].author":           {"J. R. R. Tolkien", "Herman Melville", "Evelyn Waugh", "Nigel Rees"},
+			"$..book[-2:].title":             {"Moby Dick", "The Lord of the Rings"},
+			"$..book[::0].title":             {},
+			"$..book[4].title":               {},
+			"$.store.bicycle[0]":             {},
+			"$.store .bicycle [ 'color' ]":   {"red"},
+			`$["store"].bicycle.color`:       {"red"},
+			"$.store.bicycle.*":              {"red", 399.0},
+		} {
+			q, err := parseJSONPath(expr)
+			if err != nil {
+				t.Errorf("%s: %v", expr, err)
+				continue
+			}
+			got := q.eval(v, v)
+			if got == nil {
+				got = []interface{}{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %v, got %v", expr, want, got)
+			}
+		}
+	})
+
+	// Test filters with comparisons, logic, existence and functions
+	t.Run("Filters", func(t *testing.T) {
+		for expr, want := range map[string][]interface{}{
+			"$..book[?@.isbn].title":                                     {"Moby Dick", "The Lord of the Rings"},
+			"$..book[?!@.isbn].title":                                    {"Sayings of the Century", "Sword of Honour"},
+			"$..book[?@.price < 10].title":                               {"Sayings of the Century", "Moby Dick"},
+			"$..book[?@.price <= 8.99 && @.category == 'fiction'].title": {"Moby Dick"},
+			"$..book[?@.price > 20 || @.author == 'Nigel Rees'].title":   {"Sayings of the Century", "The Lord of the Rings"},
+			"$..book[?!(@.category == 'fiction')].title":                 {"Sayings of the Century"},
+			"$..book[?@.price > $.store.book[0].price].title":            {"Sword of Honour", "Moby Dick", "The Lord of the Rings"},
+			"$..book[?@.missing == @.other].title": {
+				"Sayings of the Century", "Sword of Honour", "Moby Dick", "The Lord of the Rings",
+			},
+			"$..book[?@.isbn == null].title":                    {},
+			"$..book[?length(@.title) > 15].title":              {"Sayings of the Century", "The Lord of the Rings"},
+			"$..book[?match(@.author, 'H.*')].title":            {"Moby Dick"},
+			"$..book[?search(@.author, 'R')].author":            {"Nigel Rees", "J. R. R. Tolkien"},
+			"$.store[?count(@.*) == 2].color":                   {"red"},
+			"$..book[?value(@..isbn) == '0-553-21311-3'].title": {"Moby Dick"},
+			"$..book[?@.author > 'I'].author":                   {"Nigel Rees", "J. R. R. Tolkien"},
+			"$..book[?@.price == 'cheap'].title":                {},
+		} {
+			q, err := parseJSONPath(expr)
+			if err != nil {
+				t.Errorf("%s: %v", expr, err)
+				continue
+			}
+			got := q.eval(v, v)
+			if got == nil {
+				got = []interface{}{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %v, got %v", expr, want, got)
+			}
+		}
+	})
+
+	// Test the cases of testdata/jsonpath-cts.json, which follows the
+	// format of the jsonpath-compliance-test-suite: a document and the
+	// nodes selected, with "results" listing every valid order where
+	// object members make it ambiguous, or an invalid selector
+	t.Run("ComplianceSuite", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "jsonpath-cts.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var suite struct {
+			Tests []struct {
+				Name            string
+				Selector        string
+				Document        json.RawMessage
+				Result          json.RawMessage
+				Results         []json.RawMessage
+				InvalidSelector bool `json:"invalid_selector"`
+			}
+		}
+		if err := json.Unmarshal(data, &suite); err != nil {
+			t.Fatal(err)
+		}
+		decode := func(raw json.RawMessage) interface{} {
+			v, err := decodeJSON(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err = queryValue(v); err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+
+		for _, tc := range suite.Tests {
+			q, err := parseJSONPath(tc.Selector)
+			if tc.InvalidSelector {
+				if errorKind(err) != kindInvalidArgument {
+					t.Errorf("%s: expected %q to be invalid", tc.Name, tc.Selector)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", tc.Name, err)
+				continue
+			}
+
+			doc := decode(tc.Document)
+			got := q.eval(doc, doc)
+			if got == nil {
+				got = []interface{}{}
+			}
+			want := tc.Results
+			if tc.Result != nil {
+				want = append(want, tc.Result)
+			}
+			matched := false
+			for _, w := range want {
+				matched = matched || reflect.DeepEqual(got, decode(w))
+			}
+			if !matched {
+				t.Errorf("%s: %s selected %v", tc.Name, tc.Selector, got)
+			}
+		}
+	})
+
+	// Test that malformed queries fail with invalid_argument
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{
+			"", "store", "$.", "$[", "$['a'", "$[01]", "$[-0]", "$[9007199254740992]", "$.1a", `$["\q"]`,
+			"$[?@.a == 1", "$[?@.* == 1]", "$[?1]", "$[?length(@.a)]", "$[?count(1) == 1]",
+			"$[?unknown(@)]", "$[?match(@.a) == true]", "$..", "$.a b",
+		} {
+			if _, err := parseJSONPath(expr); errorKind(err) != kindInvalidArgument {
+				t.Errorf("%q: expected invalid_argument, got %v", expr, err)
+			}
+		}
+	})
+}
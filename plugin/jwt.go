@@ -0,0 +1,370 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Clock skew allowed when checking exp and nbf
+const jwtLeeway = time.Minute
+
+// Return structs
+type jwtToken struct {
+	Header map[string]interface{}
+	Claims map[string]interface{}
+}
+
+type jwtVerification struct {
+	Valid     bool
+	Error     string
+	KeyID     string `sentinel:"key_id"`
+	Algorithm string
+	Header    map[string]interface{}
+	Claims    map[string]interface{}
+}
+
+// jwk is a single key of a JSON Web Key Set (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtAlgorithm describes a JWS signing algorithm (RFC 7518)
+type jwtAlgorithm struct {
+	kty  string
+	hash crypto.Hash
+	pss  bool
+	crv  string
+}
+
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"RS256": {kty: "RSA", hash: crypto.SHA256},
+	"RS384": {kty: "RSA", hash: crypto.SHA384},
+	"RS512": {kty: "RSA", hash: crypto.SHA512},
+	"PS256": {kty: "RSA", hash: crypto.SHA256, pss: true},
+	"PS384": {kty: "RSA", hash: crypto.SHA384, pss: true},
+	"PS512": {kty: "RSA", hash: crypto.SHA512, pss: true},
+	"ES256": {kty: "EC", hash: crypto.SHA256, crv: "P-256"},
+	"ES384": {kty: "EC", hash: crypto.SHA384, crv: "P-384"},
+	"ES512": {kty: "EC", hash: crypto.SHA512, crv: "P-521"},
+	"EdDSA": {kty: "OKP", crv: "Ed25519"},
+}
+
+// decodeJWT splits a compact JWS into its decoded header and claims, and
+// the signing input and signature. It does not verify anything.
+func decodeJWT(token string) (*jwtToken, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, jwtDecodeError(fmt.Errorf("expected 3 dot-separated parts, got %d", len(parts)))
+	}
+
+	tok := &jwtToken{}
+	for i, dst := range []*map[string]interface{}{&tok.Header, &tok.Claims} {
+		raw, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, nil, nil, jwtDecodeError(err)
+		}
+		v, err := decodeJSON(raw)
+		if err != nil {
+			return nil, nil, nil, jwtDecodeError(err)
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil, nil, jwtDecodeError(errors.New("header and claims must be JSON objects"))
+		}
+		*dst = m
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, jwtDecodeError(err)
+	}
+	return tok, []byte(parts[0] + "." + parts[1]), sig, nil
+}
+
+func jwtDecodeError(err error) error {
+	return &kindError{Kind: kindDecode, Err: fmt.Errorf("malformed JWT: %s", err)}
+}
+
+// verifyJWT checks the signature of token against the keys in jwks, and
+// its exp and nbf claims as of now. A token that does not verify is
+// reported through Valid and Error; a malformed token fails with decode
+// and an unreadable key set with invalid_key. Keys that cannot be used,
+// such as those of other types or with malformed material, are skipped;
+// it fails with invalid_key only when no key for the token is usable.
+func verifyJWT(token string, jwks []byte, now time.Time) (*jwtVerification, error) {
+	tok, input, sig, err := decodeJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, invalidKey(fmt.Errorf("parsing JWKS: %s", err))
+	}
+
+	res := &jwtVerification{Header: tok.Header, Claims: tok.Claims}
+	res.Algorithm, _ = tok.Header["alg"].(string)
+	res.KeyID, _ = tok.Header["kid"].(string)
+
+	alg, ok := jwtAlgorithms[res.Algorithm]
+	if !ok {
+		res.Error = fmt.Sprintf("unsupported algorithm %q", res.Algorithm)
+		return res, nil
+	}
+
+	var candidates []*jwk
+	for _, raw := range set.Keys {
+		k := &jwk{}
+		if err := json.Unmarshal(raw, k); err != nil {
+			continue // a key with fields of unexpected types, not one we can use
+		}
+		if (res.KeyID == "" || k.Kid == res.KeyID) && k.Kty == alg.kty &&
+			(k.Alg == "" || k.Alg == res.Algorithm) && (k.Use == "" || k.Use == "sig") {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 0 {
+		res.Error = fmt.Sprintf("no %s key matching kid %q in JWKS", alg.kty, res.KeyID)
+		return res, nil
+	}
+
+	verified, usable := false, 0
+	var unusable []string
+	for _, k := range candidates {
+		pub, err := k.publicKey()
+		if err != nil {
+			unusable = append(unusable, fmt.Sprintf("key %q: %s", k.Kid, err))
+			continue
+		}
+		usable++
+		if alg.verify(pub, input, sig) {
+			verified = true
+			break
+		}
+	}
+	if usable == 0 {
+		return nil, invalidKey(errors.New(strings.Join(unusable, "; ")))
+	}
+	if !verified {
+		res.Error = "signature does not match"
+		return res, nil
+	}
+
+	if err := checkJWTTimes(tok.Claims, now); err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	res.Valid = true
+	return res, nil
+}
+
+// checkJWTTimes checks the exp and nbf claims, if present
+func checkJWTTimes(claims map[string]interface{}, now time.Time) error {
+	if exp, ok := numericDate(claims["exp"]); ok && now.After(exp.Add(jwtLeeway)) {
+		return fmt.Errorf("token expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(jwtLeeway).Before(nbf) {
+		return fmt.Errorf("token not valid before %s", nbf.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func numericDate(v interface{}) (time.Time, bool) {
+	switch n := v.(type) {
+	case int64:
+		return time.Unix(n, 0), true
+	case float64:
+		return time.Unix(int64(n), 0), true
+	}
+	return time.Time{}, false
+}
+
+func (a jwtAlgorithm) verify(pub crypto.PublicKey, input, sig []byte) bool {
+	if a.kty == "OKP" {
+		key, ok := pub.(ed25519.PublicKey)
+		return ok && ed25519.Verify(key, input, sig)
+	}
+
+	h := a.hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if a.pss {
+			return rsa.VerifyPSS(key, a.hash, digest, sig, nil) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, a.hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// JWS ECDSA signatures are r and s concatenated, not ASN.1
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if key.Curve.Params().Name != a.crv || len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// publicKey decodes the key material of k
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %s", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %s", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %s", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %s", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("x: not an Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeJSON decodes data keeping integers as int64 rather than float64,
+// so Sentinel sees them as ints
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return fromJSONNumbers(v), nil
+}
+
+func fromJSONNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		for i := range x {
+			x[i] = fromJSONNumbers(x[i])
+		}
+	case map[string]interface{}:
+		for k := range x {
+			x[k] = fromJSONNumbers(x[k])
+		}
+	}
+	return v
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "jwt_decode",
+			Args:        []argSpec{{"token", "string"}},
+			Returns:     "map",
+			Description: "Header and claims of a JWT, without verifying its signature. Fails with decode if the token is malformed.",
+			Example:     `pd.jwt_decode(pd.getenv("TFC_WORKLOAD_IDENTITY_TOKEN")).claims.sub`,
+			Shape:       (*jwtToken)(nil),
+			Redact:      true,
+			New: func(r *Root) interface{} {
+				return func(token string) (interface{}, error) {
+					tok, _, _, err := decodeJWT(token)
+					if err != nil {
+						return nil, err
+					}
+					return tok, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "jwt_verify",
+			Args:        []argSpec{{"token", "string"}, {"jwks_file", "string"}},
+			Returns:     "map",
+			Description: "Verifies a JWT's signature against a local JWKS file, and its exp and nbf claims. Returns valid, the reason if not, and the key ID, algorithm, header and claims.",
+			Example:     `pd.jwt_verify(pd.getenv("TFC_WORKLOAD_IDENTITY_TOKEN"), "./jwks.json").valid`,
+			Shape:       (*jwtVerification)(nil),
+			Redact:      true,
+			New: func(r *Root) interface{} {
+				return func(token, jwksFile string) (interface{}, error) {
+					jwks, err := r.readFile(jwksFile)
+					if err != nil {
+						return nil, r.fileError(err)
+					}
+					res, err := verifyJWT(token, jwks, time.Now())
+					if err != nil {
+						var ke *kindError
+						if errors.As(err, &ke) && ke.Kind == kindInvalidKey {
+							ke.Path = jwksFile
+						}
+						return nil, err
+					}
+					return res, nil
+				}
+			},
+		},
+	)
+}
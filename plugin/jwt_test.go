@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signJWT builds a compact JWS with the given header and claims, signed by sign
+func signJWT(header, claims map[string]interface{}, sign func([]byte) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := b64url(h) + "." + b64url(c)
+	return input + "." + b64url(sign([]byte(input)))
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Now()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64url(rsaKey.N.Bytes()), "e": b64url(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64url(ecKey.X.FillBytes(make([]byte, 32))), "y": b64url(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": b64url(edPub)},
+	}})
+
+	claims := map[string]interface{}{
+		"sub": "organization:acme:project:default:workspace:prod:run_phase:plan",
+		"aud": "aws.workload.identity",
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+	}
+	signRSA := func(in []byte) []byte {
+		digest := sha256.Sum256(in)
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		return sig
+	}
+	signEC := func(in []byte) []byte {
+		digest := sha256.Sum256(in)
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	signEd := func(in []byte) []byte {
+		return ed25519.Sign(edPriv, in)
+	}
+
+	rsaToken := signJWT(map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": "rsa-1"}, claims, signRSA)
+
+	// Test that header and claims are decoded, with integer claims kept as ints
+	t.Run("Decode", func(t *testing.T) {
+		result, err := (&Root{}).Func("jwt_decode").(func(string) (interface{}, error))(rsaToken)
+		if err != nil {
+			t.Fatalf("jwt_decode should not return error: %v", err)
+		}
+
+		tok := result.(*jwtToken)
+		if tok.Header["kid"] != "rsa-1" || tok.Claims["aud"] != "aws.workload.identity" {
+			t.Errorf("Unexpected header or claims %+v", tok)
+		}
+		if _, ok := tok.Claims["exp"].(int64); !ok {
+			t.Errorf("Expected exp to be an int64, got %T", tok.Claims["exp"])
+		}
+
+		for _, bad := range []string{"", "a.b", "a.b.c", "e30.bm90IGpzb24.c2ln"} {
+			if _, _, _, err := decodeJWT(bad); errorKind(err) != kindDecode {
+				t.Errorf("Expected %s error for %q, got %v", kindDecode, bad, err)
+			}
+		}
+	})
+
+	// Test that RS256, ES256 and EdDSA tokens verify
+	t.Run("Verify", func(t *testing.T) {
+		tokens := map[string]string{
+			"RS256": rsaToken,
+			"ES256": signJWT(map[string]interface{}{"alg": "ES256", "kid": "ec-1"}, claims, signEC),
+			"EdDSA": signJWT(map[string]interface{}{"alg": "EdDSA"}, claims, signEd),
+		}
+		for alg, token := range tokens {
+			res, err := verifyJWT(token, jwks, now)
+			if err != nil {
+				t.Fatalf("verifyJWT should not return error: %v", err)
+			}
+			if !res.Valid || res.Algorithm != alg {
+				t.Errorf("Expected a valid %s token, got %+v", alg, res)
+			}
+		}
+	})
+
+	// Test that bad signatures, unknown keys and expired tokens are invalid
+	t.Run("Invalid", func(t *testing.T) {
+		parts := strings.Split(rsaToken, ".")
+		forged := parts[0] + "." + b64url([]byte(`{"sub":"attacker"}`)) + "." + parts[2]
+
+		expired := map[string]interface{}{"sub": "x", "exp": now.Add(-time.Hour).Unix()}
+		cases := map[string]string{
+			"signature does not match": forged,
+			"no RSA key":               signJWT(map[string]interface{}{"alg": "RS256", "kid": "other"}, claims, signRSA),
+			"unsupported algorithm":    signJWT(map[string]interface{}{"alg": "none"}, claims, func([]byte) []byte { return nil }),
+			"token expired":            signJWT(map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}, expired, signRSA),
+		}
+		for want, token := range cases {
+			res, err := verifyJWT(token, jwks, now)
+			if err != nil {
+				t.Fatalf("verifyJWT should not return error: %v", err)
+			}
+			if res.Valid || !strings.Contains(res.Error, want) {
+				t.Errorf("Expected %q, got %+v", want, res)
+			}
+		}
+
+		if _, err := verifyJWT(rsaToken, []byte("not json"), now); errorKind(err) != kindInvalidKey {
+			t.Errorf("Expected %s error, got %v", kindInvalidKey, err)
+		}
+	})
+
+	// Test that keys which cannot be used are skipped rather than failing
+	// the verification, unless no key for the token is usable
+	t.Run("MixedKeySet", func(t *testing.T) {
+		good := map[string]interface{}{"kty": "RSA", "kid": "rsa-1", "n": b64url(rsaKey.N.Bytes()), "e": b64url(big.NewInt(int64(rsaKey.E)).Bytes())}
+		broken := []interface{}{
+			map[string]interface{}{"kty": "RSA", "kid": "rsa-1", "n": 12345, "e": "AQAB"},
+			map[string]interface{}{"kty": "RSA", "kid": "rsa-1", "n": "not base64!", "e": "AQAB"},
+			map[string]interface{}{"kty": "oct", "kid": "rsa-1", "k": "c2VjcmV0"},
+			map[string]interface{}{"kty": "EC", "kid": "rsa-1", "crv": "P-999", "x": "AA", "y": "AA"},
+		}
+		mixed, _ := json.Marshal(map[string]interface{}{"keys": append(broken, good)})
+		res, err := verifyJWT(rsaToken, mixed, now)
+		if err != nil || !res.Valid {
+			t.Errorf("Expected a valid token, got %+v, %v", res, err)
+		}
+
+		unusable, _ := json.Marshal(map[string]interface{}{"keys": broken})
+		if _, err := verifyJWT(rsaToken, unusable, now); errorKind(err) != kindInvalidKey {
+			t.Errorf("Expected %s error with no usable key, got %v", kindInvalidKey, err)
+		}
+	})
+
+	// Test the function with a JWKS file, and that the token is not logged
+	t.Run("Function", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		os.WriteFile(path, jwks, 0644)
+
+		var buf bytes.Buffer
+		root := &Root{}
+		root.logger = hclog.New(&hclog.LoggerOptions{Level: hclog.Debug, Output: &buf})
+
+		result, err := root.Func("jwt_verify").(func(string, string) (interface{}, error))(rsaToken, path)
+		if err != nil || !result.(*jwtVerification).Valid {
+			t.Errorf("Expected a valid token, got %+v, %v", result, err)
+		}
+		if strings.Contains(buf.String(), rsaToken[:20]) || !strings.Contains(buf.String(), redactedArgs) {
+			t.Errorf("Expected the token to be redacted in log output %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Longest string argument that is logged in full
+const logArgMaxLen = 32
+
+// Logged in place of the arguments of functions that take secrets
+const redactedArgs = "(redacted)"
+
+var errorTyp = reflect.TypeOf((*error)(nil)).Elem()
+
+// newLogger builds the logger for the configured level and format. The
+// plugin logs to stderr, which go-plugin forwards to the Sentinel host, so
+// JSON is the default format: the host parses it and keeps the levels.
+func newLogger(c *config) hclog.Logger {
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       "sentinel-plugin-demo",
+		Level:      c.LogLevel,
+		Output:     os.Stderr,
+		JSONFormat: c.LogFormat == "json",
+	})
+}
+
+// log returns the configured logger, or a logger that discards everything
+// if Configure was never called
+func (r *Root) log() hclog.Logger {
+	if r.logger == nil {
+		return hclog.NewNullLogger()
+	}
+	return r.logger
+}
+
+// instrumentFunc wraps a registered function so every call is logged
+// with a summary of its arguments (unless s.Redact is set), its duration
+// and any error, and counted in the metrics. The wrapper
+// has the same type as fn, so the framework calls it like the original.
+func (r *Root) instrumentFunc(s *funcSpec, fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	returnsErr := t.NumOut() == 2 && t.Out(1) == errorTyp
+
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		out := v.Call(args)
+
+		var err error
+		if returnsErr && !out[1].IsNil() {
+			err = out[1].Interface().(error)
+		}
+		summary := redactedArgs
+		if !s.Redact {
+			summary = summarizeArgs(args)
+		}
+		r.recordCall("function", s.Name, summary, time.Since(start), err)
+		return out
+	}).Interface()
+}
+
+// instrumentGet is instrumentFunc for property reads
+func (r *Root) instrumentGet(name string, get func(*Root) (interface{}, error)) (interface{}, error) {
+	start := time.Now()
+	v, err := get(r)
+	r.recordCall("property", name, "", time.Since(start), err)
+	return v, err
+}
+
+// recordCall logs a call and adds it to the metrics
+func (r *Root) recordCall(kind, name, args string, d time.Duration, err error) {
+	r.stats().observe(kind, name, d, err)
+
+	l := r.log()
+	if err != nil {
+		l.Error("call failed", kind, name, "args", args, "duration", d, "error_kind", errorKind(err), "error", err)
+		return
+	}
+	l.Debug("call", kind, name, "args", args, "duration", d)
+}
+
+// summarizeArgs renders arguments for the log without dumping large or
+// sensitive values: long strings are truncated and collections are
+// reduced to their length.
+func summarizeArgs(args []reflect.Value) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = summarizeValue(a)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func summarizeValue(v reflect.Value) string {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "null"
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if len(s) > logArgMaxLen {
+			return fmt.Sprintf("%q...(%d bytes)", s[:logArgMaxLen], len(s))
+		}
+		return fmt.Sprintf("%q", s)
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("list(%d)", v.Len())
+	case reflect.Map:
+		return fmt.Sprintf("map(%d)", v.Len())
+	case reflect.Struct:
+		return "map"
+	}
+	return fmt.Sprint(v.Interface())
+}
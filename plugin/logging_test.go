@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestLogging(t *testing.T) {
+	// Create a root logging to a buffer
+	var buf bytes.Buffer
+	root := &Root{}
+	root.logger = hclog.New(&hclog.LoggerOptions{Level: hclog.Debug, Output: &buf, JSONFormat: true})
+
+	// Test that instrumented functions keep their Go signature
+	t.Run("KeepsSignatures", func(t *testing.T) {
+		if _, ok := root.Func("getfile").(func(string) (interface{}, error)); !ok {
+			t.Error("getfile should still be a func(string) (interface{}, error)")
+		}
+		if _, ok := root.Func("getallenvs").(func() interface{}); !ok {
+			t.Error("getallenvs should still be a func() interface{}")
+		}
+	})
+
+	// Test that calls are logged with arguments and duration
+	t.Run("LogsCalls", func(t *testing.T) {
+		buf.Reset()
+		root.Func("getenv").(func(string) interface{})("HOME")
+
+		out := buf.String()
+		for _, want := range []string{`"function":"getenv"`, `"args":"\"HOME\""`, `"duration"`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in log output %s", want, out)
+			}
+		}
+	})
+
+	// Test that failures are logged at error level with their kind
+	t.Run("LogsErrors", func(t *testing.T) {
+		buf.Reset()
+		root.Func("decode").(func(string, string) (interface{}, error))("zz", "hex")
+
+		out := buf.String()
+		for _, want := range []string{`"@level":"error"`, `"error_kind":"decode"`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in log output %s", want, out)
+			}
+		}
+	})
+
+	// Test that swallowed file errors are logged
+	t.Run("LogsSwallowedFileErrors", func(t *testing.T) {
+		buf.Reset()
+		root.Func("getfile").(func(string) (interface{}, error))("/absolutely/non/existent/file/path/12345.txt")
+
+		if !strings.Contains(buf.String(), "no such file") && !strings.Contains(buf.String(), "cannot find") {
+			t.Errorf("Expected the file error in log output %s", buf.String())
+		}
+	})
+
+	// Test that property reads are logged
+	t.Run("LogsProperties", func(t *testing.T) {
+		buf.Reset()
+		root.Get("pwd")
+
+		if !strings.Contains(buf.String(), `"property":"pwd"`) {
+			t.Errorf("Expected pwd in log output %s", buf.String())
+		}
+	})
+
+	// Test that an unconfigured root does not log
+	t.Run("UnconfiguredIsSilent", func(t *testing.T) {
+		if (&Root{}).log().IsError() {
+			t.Error("Unconfigured root should discard logs")
+		}
+	})
+}
+
+func TestSummarizeArgs(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	args := []reflect.Value{
+		reflect.ValueOf("short"),
+		reflect.ValueOf(long),
+		reflect.ValueOf([]string{"a", "b"}),
+		reflect.ValueOf(map[string]int{"a": 1}),
+		reflect.ValueOf(int64(7)),
+	}
+
+	want := `"short", "` + strings.Repeat("x", logArgMaxLen) + `"...(100 bytes), list(2), map(1), 7`
+	if got := summarizeArgs(args); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,226 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds, in seconds, of the call duration histogram buckets
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// Prefix of every exported metric name
+const metricPrefix = "sentinel_plugin_demo_"
+
+// metrics holds in-process counters for every call since the plugin
+// started. It is safe for concurrent use.
+type metrics struct {
+	mu        sync.Mutex
+	calls     map[callKey]*callMetrics
+	bytesRead int64
+}
+
+type callKey struct {
+	kind string // function or property
+	name string
+}
+
+type callMetrics struct {
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+	buckets []int64 // counts per durationBuckets, not cumulative
+	errors  map[string]int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{calls: map[callKey]*callMetrics{}}
+}
+
+// observe records a single call
+func (m *metrics) observe(kind, name string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := callKey{kind, name}
+	c, ok := m.calls[k]
+	if !ok {
+		c = &callMetrics{buckets: make([]int64, len(durationBuckets)), errors: map[string]int64{}}
+		m.calls[k] = c
+	}
+
+	c.count++
+	c.sum += d
+	if d > c.max {
+		c.max = d
+	}
+	for i, le := range durationBuckets {
+		if d.Seconds() <= le {
+			c.buckets[i]++
+			break
+		}
+	}
+	if err != nil {
+		kind := errorKind(err)
+		if kind == "" {
+			kind = "other"
+		}
+		c.errors[kind]++
+	}
+}
+
+// addBytesRead counts bytes read from disk
+func (m *metrics) addBytesRead(n int) {
+	m.mu.Lock()
+	m.bytesRead += int64(n)
+	m.mu.Unlock()
+}
+
+// Return structs
+type statsSnapshot struct {
+	BytesRead int64
+	Calls     map[string]*callSnapshot
+}
+
+type callSnapshot struct {
+	Kind         string
+	Count        int64
+	Errors       map[string]int64
+	TotalSeconds float64
+	MeanSeconds  float64
+	MaxSeconds   float64
+}
+
+// snapshot returns a copy of the counters, keyed by function or property
+// name
+func (m *metrics) snapshot() *statsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &statsSnapshot{BytesRead: m.bytesRead, Calls: map[string]*callSnapshot{}}
+	for k, c := range m.calls {
+		errs := make(map[string]int64, len(c.errors))
+		for kind, n := range c.errors {
+			errs[kind] = n
+		}
+		s.Calls[k.name] = &callSnapshot{
+			Kind:         k.kind,
+			Count:        c.count,
+			Errors:       errs,
+			TotalSeconds: c.sum.Seconds(),
+			MeanSeconds:  c.sum.Seconds() / float64(c.count),
+			MaxSeconds:   c.max.Seconds(),
+		}
+	}
+	return s
+}
+
+// writePrometheus writes the counters in the Prometheus text exposition
+// format, sorted so the output is stable.
+func (m *metrics) writePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]callKey, 0, len(m.calls))
+	for k := range m.calls {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	var b strings.Builder
+	labels := func(k callKey) string {
+		return fmt.Sprintf("kind=%q,name=%q", k.kind, k.name)
+	}
+
+	b.WriteString("# HELP " + metricPrefix + "calls_total Calls by function or property.\n")
+	b.WriteString("# TYPE " + metricPrefix + "calls_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%scalls_total{%s} %d\n", metricPrefix, labels(k), m.calls[k].count)
+	}
+
+	b.WriteString("# HELP " + metricPrefix + "call_errors_total Failed calls by function or property and error kind.\n")
+	b.WriteString("# TYPE " + metricPrefix + "call_errors_total counter\n")
+	for _, k := range keys {
+		c := m.calls[k]
+		for _, kind := range sortedKeys(c.errors) {
+			fmt.Fprintf(&b, "%scall_errors_total{%s,error_kind=%q} %d\n", metricPrefix, labels(k), kind, c.errors[kind])
+		}
+	}
+
+	b.WriteString("# HELP " + metricPrefix + "call_duration_seconds Call duration by function or property.\n")
+	b.WriteString("# TYPE " + metricPrefix + "call_duration_seconds histogram\n")
+	for _, k := range keys {
+		c := m.calls[k]
+		var cumulative int64
+		for i, le := range durationBuckets {
+			cumulative += c.buckets[i]
+			fmt.Fprintf(&b, "%scall_duration_seconds_bucket{%s,le=%q} %d\n",
+				metricPrefix, labels(k), strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "%scall_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", metricPrefix, labels(k), c.count)
+		fmt.Fprintf(&b, "%scall_duration_seconds_sum{%s} %g\n", metricPrefix, labels(k), c.sum.Seconds())
+		fmt.Fprintf(&b, "%scall_duration_seconds_count{%s} %d\n", metricPrefix, labels(k), c.count)
+	}
+
+	b.WriteString("# HELP " + metricPrefix + "bytes_read_total Bytes read from files.\n")
+	b.WriteString("# TYPE " + metricPrefix + "bytes_read_total counter\n")
+	fmt.Fprintf(&b, "%sbytes_read_total %d\n", metricPrefix, m.bytesRead)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTextfile writes the metrics to path for the node exporter's
+// textfile collector. The file is written next to path and renamed into
+// place so the collector never sees a partial file.
+func (m *metrics) writeTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.writePrometheus(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// stats returns the plugin's metrics, creating them on first use
+func (r *Root) stats() *metrics {
+	r.metricsOnce.Do(func() {
+		r.metrics = newMetrics()
+	})
+	return r.metrics
+}
+
+func init() {
+	registerProp(&propSpec{
+		Name:        "stats",
+		Returns:     "map",
+		Description: "Call counts, errors by kind and durations per function and property since the plugin started, and total bytes read from files.",
+		Example:     `pd.stats.calls["getfile"].count`,
+		Shape:       (*statsSnapshot)(nil),
+		Get: func(r *Root) (interface{}, error) {
+			return r.stats().snapshot(), nil
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off", "max_file_bytes": int64(4)})
+
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.txt")
+	os.WriteFile(small, []byte("abc"), 0644)
+	os.WriteFile(large, []byte("too large"), 0644)
+
+	getfile := root.Func("getfile").(func(string) (interface{}, error))
+	getfile(small)
+	getfile(small)
+	getfile(large)
+	root.Func("decode").(func(string, string) (interface{}, error))("zz", "hex")
+	root.Get("pwd")
+
+	// Test that the stats property reports calls, errors and bytes read
+	t.Run("StatsProperty", func(t *testing.T) {
+		result, err := root.Get("stats")
+		if err != nil {
+			t.Fatalf("stats property should not return error: %v", err)
+		}
+
+		stats, ok := result.(*statsSnapshot)
+		if !ok {
+			t.Fatal("stats should return a pointer to statsSnapshot")
+		}
+		if stats.BytesRead != 6 {
+			t.Errorf("Expected 6 bytes read, got %d", stats.BytesRead)
+		}
+
+		getfileStats := stats.Calls["getfile"]
+		if getfileStats == nil || getfileStats.Count != 3 || getfileStats.Errors[kindTooLarge] != 1 {
+			t.Errorf("Unexpected getfile stats %+v", getfileStats)
+		}
+		if stats.Calls["decode"].Errors[kindDecode] != 1 {
+			t.Errorf("Unexpected decode stats %+v", stats.Calls["decode"])
+		}
+		if stats.Calls["pwd"] == nil || stats.Calls["pwd"].Kind != "property" {
+			t.Errorf("Unexpected pwd stats %+v", stats.Calls["pwd"])
+		}
+	})
+
+	// Test that the textfile is written on Close
+	t.Run("WritesTextfileOnClose", func(t *testing.T) {
+		promFile := filepath.Join(dir, "plugin.prom")
+		root.config.MetricsFile = promFile
+		if err := root.Close(); err != nil {
+			t.Fatalf("Close should not return error: %v", err)
+		}
+
+		data, err := os.ReadFile(promFile)
+		if err != nil {
+			t.Fatalf("Failed to read metrics file: %v", err)
+		}
+		for _, want := range []string{
+			`sentinel_plugin_demo_calls_total{kind="function",name="getfile"} 3`,
+			`sentinel_plugin_demo_call_errors_total{kind="function",name="getfile",error_kind="too_large"} 1`,
+			`sentinel_plugin_demo_call_duration_seconds_bucket{kind="function",name="getfile",le="+Inf"} 3`,
+			`sentinel_plugin_demo_call_duration_seconds_count{kind="property",name="pwd"} 1`,
+			`sentinel_plugin_demo_bytes_read_total 6`,
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("Expected %s in metrics file:\n%s", want, data)
+			}
+		}
+	})
+
+	// Test that each instance replaces the file whole with its own
+	// counters, leaving no temporary files behind
+	t.Run("ReplacesTextfile", func(t *testing.T) {
+		promFile := filepath.Join(dir, "plugin.prom")
+		other := &Root{}
+		other.Configure(map[string]interface{}{"log_level": "off", "metrics_file": promFile})
+		other.Get("pwd")
+		if err := other.Close(); err != nil {
+			t.Fatalf("Close should not return error: %v", err)
+		}
+
+		data, err := os.ReadFile(promFile)
+		if err != nil {
+			t.Fatalf("Failed to read metrics file: %v", err)
+		}
+		if strings.Contains(string(data), `name="getfile"`) || !strings.Contains(string(data), `sentinel_plugin_demo_calls_total{kind="property",name="pwd"} 1`) {
+			t.Errorf("Expected only the second instance's counters:\n%s", data)
+		}
+		if tmp, _ := filepath.Glob(promFile + ".tmp*"); len(tmp) != 0 {
+			t.Errorf("Expected no temporary files, got %v", tmp)
+		}
+	})
+
+	// Test that Close through the SDK plugin reaches Root
+	t.Run("PluginForwardsClose", func(t *testing.T) {
+		if _, ok := New().(io.Closer); !ok {
+			t.Error("New should return a plugin implementing io.Closer")
+		}
+	})
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	m := newMetrics()
+	m.observe("function", "f", 2*time.Millisecond, nil)
+	m.observe("function", "f", 20*time.Second, nil)
+
+	var buf bytes.Buffer
+	m.writePrometheus(&buf)
+	out := buf.String()
+
+	// Test that buckets are cumulative and slow calls only land in +Inf
+	for _, want := range []string{
+		`_bucket{kind="function",name="f",le="0.001"} 0`,
+		`_bucket{kind="function",name="f",le="0.005"} 1`,
+		`_bucket{kind="function",name="f",le="10"} 1`,
+		`_bucket{kind="function",name="f",le="+Inf"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Return structs
+type pgpResult struct {
+	Valid              bool
+	KeyID              string `sentinel:"key_id"` // key that made the signature, which may be a subkey
+	Fingerprint        string
+	PrimaryFingerprint string
+	Identities         []string
+	Created            string // RFC 3339
+	Error              string
+}
+
+// verifyPGP checks a detached OpenPGP signature of data, binary or
+// armored, against the keys in keyring. A signature that does not verify
+// (unknown signer, bad signature, expired key) is reported through Valid
+// and Error; a keyring that cannot be read fails with invalid_key.
+func verifyPGP(data, sig, keyring []byte) (*pgpResult, error) {
+	keys, err := readPGPKeyRing(keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		block, err := armor.Decode(bytes.NewReader(sig))
+		if err != nil {
+			return &pgpResult{Error: err.Error()}, nil
+		}
+		// The decoded body is smaller than the armored file, which was
+		// already read within max_file_bytes
+		sig, err = io.ReadAll(block.Body)
+		if err != nil {
+			return &pgpResult{Error: err.Error()}, nil
+		}
+	}
+
+	s, signer, err := openpgp.VerifyDetachedSignature(keys, bytes.NewReader(data), bytes.NewReader(sig), nil)
+	res := &pgpResult{Valid: err == nil}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if s == nil || signer == nil {
+		return res, nil
+	}
+
+	res.Created = s.CreationTime.UTC().Format(time.RFC3339)
+	res.PrimaryFingerprint = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	if key := signingKey(signer, s); key != nil {
+		res.KeyID = key.KeyIdString()
+		res.Fingerprint = fmt.Sprintf("%X", key.Fingerprint)
+	}
+	for name := range signer.Identities {
+		res.Identities = append(res.Identities, name)
+	}
+	sort.Strings(res.Identities)
+	return res, nil
+}
+
+// readPGPKeyRing reads an armored keyring, as exported by gpg --armor
+// --export, falling back to a binary one
+func readPGPKeyRing(data []byte) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+	var err error
+	if bytes.Contains(data, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keys, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, invalidKey(err)
+	}
+	if len(keys) == 0 {
+		return nil, invalidKey(errors.New("keyring contains no keys"))
+	}
+	return keys, nil
+}
+
+// signingKey returns the primary key or subkey of e that issued s
+func signingKey(e *openpgp.Entity, s *packet.Signature) *packet.PublicKey {
+	if s.IssuerKeyId == nil {
+		return nil
+	}
+	if e.PrimaryKey.KeyId == *s.IssuerKeyId {
+		return e.PrimaryKey
+	}
+	for _, sub := range e.Subkeys {
+		if sub.PublicKey.KeyId == *s.IssuerKeyId {
+			return sub.PublicKey
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "verify_pgp",
+		Args:        []argSpec{{"datafile", "string"}, {"sigfile", "string"}, {"keyring", "string"}},
+		Returns:     "map",
+		Description: "Verifies a detached OpenPGP signature, binary or armored, against a keyring file offline. Returns whether it is valid, and the signer's key ID, fingerprints, identities and the signature's creation time.",
+		Example:     `pd.verify_pgp("./SHA256SUMS", "./SHA256SUMS.sig", "./hashicorp.asc").valid`,
+		Shape:       (*pgpResult)(nil),
+		New: func(r *Root) interface{} {
+			return func(datafile, sigfile, keyring string) (interface{}, error) {
+				keys, err := r.readFile(keyring)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+				sig, err := r.readFile(sigfile)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+				data, err := r.readFile(datafile)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+
+				res, err := verifyPGP(data, sig, keys)
+				if err != nil {
+					var ke *kindError
+					if errors.As(err, &ke) {
+						ke.Path = keyring
+					}
+					return nil, err
+				}
+				return res, nil
+			}
+		},
+	})
+}
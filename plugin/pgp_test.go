@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func pgpEntity(t *testing.T, name string) (*openpgp.Entity, []byte) {
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, _ := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err := e.Serialize(w); err != nil {
+		t.Fatalf("Failed to export key: %v", err)
+	}
+	w.Close()
+	return e, buf.Bytes()
+}
+
+func TestVerifyPGP(t *testing.T) {
+	data := []byte("abc123  terraform_1.9.0_linux_amd64.zip\n")
+	signer, keyring := pgpEntity(t, "Release")
+	_, otherKeyring := pgpEntity(t, "Other")
+
+	var binarySig, armoredSig bytes.Buffer
+	if err := openpgp.DetachSign(&binarySig, signer, bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	openpgp.ArmoredDetachSign(&armoredSig, signer, bytes.NewReader(data), nil)
+
+	// Test that binary and armored signatures verify and report the signer
+	t.Run("ValidSignature", func(t *testing.T) {
+		for _, sig := range [][]byte{binarySig.Bytes(), armoredSig.Bytes()} {
+			res, err := verifyPGP(data, sig, keyring)
+			if err != nil {
+				t.Fatalf("verifyPGP should not return error: %v", err)
+			}
+			if !res.Valid || res.Error != "" {
+				t.Errorf("Expected a valid signature, got %+v", res)
+			}
+			if res.PrimaryFingerprint != fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint) {
+				t.Errorf("Unexpected primary fingerprint %s", res.PrimaryFingerprint)
+			}
+			if len(res.KeyID) != 16 || len(res.Fingerprint) != 40 || res.Created == "" {
+				t.Errorf("Expected key ID, fingerprint and creation time, got %+v", res)
+			}
+			if len(res.Identities) != 1 || res.Identities[0] != "Release <Release@example.com>" {
+				t.Errorf("Unexpected identities %v", res.Identities)
+			}
+		}
+	})
+
+	// Test that tampered data and unknown signers do not verify
+	t.Run("InvalidSignature", func(t *testing.T) {
+		res, err := verifyPGP([]byte("tampered"), binarySig.Bytes(), keyring)
+		if err != nil || res.Valid || res.Error == "" {
+			t.Errorf("Tampered data should not verify, got %+v, %v", res, err)
+		}
+		res, err = verifyPGP(data, binarySig.Bytes(), otherKeyring)
+		if err != nil || res.Valid || res.Error == "" {
+			t.Errorf("Unknown signer should not verify, got %+v, %v", res, err)
+		}
+	})
+
+	// Test that an unreadable keyring is an invalid_key error
+	t.Run("InvalidKeyring", func(t *testing.T) {
+		if _, err := verifyPGP(data, binarySig.Bytes(), []byte("not a keyring")); errorKind(err) != kindInvalidKey {
+			t.Errorf("Expected %s error, got %v", kindInvalidKey, err)
+		}
+	})
+
+	// Test the function with files
+	t.Run("Function", func(t *testing.T) {
+		dir := t.TempDir()
+		sums := filepath.Join(dir, "SHA256SUMS")
+		os.WriteFile(sums, data, 0644)
+		os.WriteFile(sums+".sig", binarySig.Bytes(), 0644)
+		os.WriteFile(filepath.Join(dir, "release.asc"), keyring, 0644)
+
+		root := &Root{}
+		verify := root.Func("verify_pgp").(func(string, string, string) (interface{}, error))
+		result, err := verify(sums, sums+".sig", filepath.Join(dir, "release.asc"))
+		if err != nil || !result.(*pgpResult).Valid {
+			t.Errorf("Expected a valid signature, got %+v, %v", result, err)
+		}
+
+		result, err = verify(sums, sums+".sig", filepath.Join(dir, "missing.asc"))
+		if result != nil || err != nil {
+			t.Errorf("Expected undefined for a missing keyring, got %v, %v", result, err)
+		}
+	})
+}
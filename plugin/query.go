@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+
+	sdk "github.com/hashicorp/sentinel-sdk"
+	"github.com/jmespath/go-jmespath"
+)
+
+// Query languages accepted by query_with and query_file_with
+const (
+	queryJMESPath = "jmespath"
+	queryJSONPath = "jsonpath"
+)
+
+// queryValue copies a document from Sentinel or decodeJSON into the form
+// both query languages expect: maps with string keys, []interface{},
+// float64 numbers, strings, bools and nil. Sentinel passes lists and maps
+// with element types of their own, such as []string or map[string]int64.
+func queryValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil, bool, string, float64:
+		return x, nil
+	case int64:
+		return float64(x), nil
+	}
+	if v == sdk.Null || v == sdk.Undefined {
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32:
+		return rv.Float(), nil
+	case reflect.Slice:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			elem, err := queryValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return list, nil
+	case reflect.Map:
+		// Object keys are strings, so Sentinel's integer keys become
+		// their decimal form
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem, err := queryValue(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(iter.Key().Interface())] = elem
+		}
+		return m, nil
+	}
+	return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("cannot query a %T", v)}
+}
+
+// fromQueryValue turns whole numbers in a result back into int64, as
+// decodeJSON does, so they compare equal to Sentinel integers
+func fromQueryValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case float64:
+		if x == float64(int64(x)) && x >= -jsonPathMaxInt && x <= jsonPathMaxInt {
+			return int64(x)
+		}
+	case []interface{}:
+		for i := range x {
+			x[i] = fromQueryValue(x[i])
+		}
+	case map[string]interface{}:
+		for k := range x {
+			x[k] = fromQueryValue(x[k])
+		}
+	}
+	return v
+}
+
+// query evaluates expression against doc. A JMESPath result of null is
+// returned as undefined; a JSONPath result is the list of selected
+// values, empty when nothing matched.
+func query(doc interface{}, expression, mode string) (interface{}, error) {
+	var eval func(interface{}) (interface{}, error)
+	switch mode {
+	case queryJMESPath:
+		jp, err := jmespath.Compile(expression)
+		if err != nil {
+			return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("invalid JMESPath %q: %s", expression, err)}
+		}
+		eval = func(v interface{}) (interface{}, error) {
+			result, err := jp.Search(v)
+			if err != nil {
+				return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("JMESPath %q: %s", expression, err)}
+			}
+			return result, nil
+		}
+	case queryJSONPath:
+		jp, err := parseJSONPath(expression)
+		if err != nil {
+			return nil, err
+		}
+		eval = func(v interface{}) (interface{}, error) {
+			nodes := jp.eval(v, v)
+			if nodes == nil {
+				nodes = []interface{}{}
+			}
+			return nodes, nil
+		}
+	default:
+		return nil, &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("unknown query mode %q, expected %s or %s", mode, queryJMESPath, queryJSONPath)}
+	}
+
+	v, err := queryValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	result, err := eval(v)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return fromQueryValue(result), nil
+}
+
+// queryFile evaluates expression against the JSON document in path. The
+// file is undefined if it cannot be read, and fails with a decode error
+// if it is not JSON.
+func (r *Root) queryFile(path, expression, mode string) (interface{}, error) {
+	data, err := r.readFile(path)
+	if err != nil {
+		return nil, r.fileError(err)
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, &kindError{Kind: kindDecode, Path: path, Err: err}
+	}
+	return query(doc, expression, mode)
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "query",
+			Args:        []argSpec{{"doc", "any"}, {"expression", "string"}},
+			Returns:     "any",
+			Description: "The result of a JMESPath expression over a map or list, or undefined if it is null. Fails with invalid_argument if the expression is malformed.",
+			Example:     `pd.query(plan, "resource_changes[?type=='aws_s3_bucket'].change.after")`,
+			New: func(r *Root) interface{} {
+				return func(doc interface{}, expression string) (interface{}, error) {
+					return query(doc, expression, queryJMESPath)
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "query_with",
+			Args:        []argSpec{{"doc", "any"}, {"expression", "string"}, {"mode", "string"}},
+			Returns:     "any",
+			Description: "query with a mode of jmespath or jsonpath. A JSONPath expression (RFC 9535) returns the list of selected values.",
+			Example:     `pd.query_with(plan, "$.resource_changes[?@.type == 'aws_s3_bucket'].address", "jsonpath")`,
+			New: func(r *Root) interface{} {
+				return func(doc interface{}, expression, mode string) (interface{}, error) {
+					return query(doc, expression, mode)
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "query_file",
+			Args:        []argSpec{{"path", "string"}, {"expression", "string"}},
+			Returns:     "any",
+			Description: "query over a JSON file, or undefined if the file cannot be read. Fails with a decode error if it is not JSON.",
+			Example:     `pd.query_file("./plan.json", "length(resource_changes[?change.actions[0]=='delete'])")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path, expression string) (interface{}, error) {
+					return r.queryFile(path, expression, queryJMESPath)
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "query_file_with",
+			Args:        []argSpec{{"path", "string"}, {"expression", "string"}, {"mode", "string"}},
+			Returns:     "any",
+			Description: "query_file with a mode of jmespath or jsonpath.",
+			Example:     `pd.query_file_with("./plan.json", "$..address", "jsonpath")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path, expression, mode string) (interface{}, error) {
+					return r.queryFile(path, expression, mode)
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	sdk "github.com/hashicorp/sentinel-sdk"
+)
+
+func TestQuery(t *testing.T) {
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+
+	// A plan as Sentinel passes it, with typed lists and maps
+	plan := map[string]interface{}{
+		"format_version": "1.2",
+		"resource_changes": []interface{}{
+			map[string]interface{}{
+				"address": "aws_s3_bucket.logs",
+				"type":    "aws_s3_bucket",
+				"change": map[string]interface{}{
+					"actions": []string{"create"},
+					"after":   map[string]interface{}{"bucket": "logs", "tags": map[string]string{"team": "infra"}},
+				},
+			},
+			map[string]interface{}{
+				"address": "aws_instance.web",
+				"type":    "aws_instance",
+				"change": map[string]interface{}{
+					"actions": []string{"delete", "create"},
+					"after":   map[string]interface{}{"count": int64(3), "ratio": 0.5, "ebs": sdk.Null},
+				},
+			},
+		},
+	}
+
+	// Test that JMESPath filters and projections return native values
+	t.Run("JMESPath", func(t *testing.T) {
+		q := root.Func("query").(func(interface{}, string) (interface{}, error))
+		for expr, want := range map[string]interface{}{
+			"resource_changes[?type=='aws_s3_bucket'].change.after": []interface{}{
+				map[string]interface{}{"bucket": "logs", "tags": map[string]interface{}{"team": "infra"}},
+			},
+			"resource_changes[?contains(change.actions, 'delete')].address": []interface{}{"aws_instance.web"},
+			"length(resource_changes)":                                      int64(2),
+			"resource_changes[1].change.after.count":                        int64(3),
+			"resource_changes[1].change.after.ratio":                        0.5,
+			"resource_changes[0].change.after.tags.team":                    "infra",
+			"resource_changes[].type | sort(@)":                             []interface{}{"aws_instance", "aws_s3_bucket"},
+			"resource_changes[?change.after.count > `2`] | [0].address":     "aws_instance.web",
+			"resource_changes[1].change.after.ebs":                          nil,
+			"missing.key":                                                   nil,
+		} {
+			got, err := q(plan, expr)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %#v, got %#v, %v", expr, want, got, err)
+			}
+		}
+
+		if got, err := q([]int64{3, 1, 2}, "max(@)"); err != nil || got != int64(3) {
+			t.Errorf("Expected 3 from a list, got %v, %v", got, err)
+		}
+		for _, expr := range []string{"resource_changes[?", "foo..bar", "abs(format_version)"} {
+			if _, err := q(plan, expr); errorKind(err) != kindInvalidArgument {
+				t.Errorf("%s: expected invalid_argument, got %v", expr, err)
+			}
+		}
+	})
+
+	// Test that the mode selects JSONPath, which returns a list of nodes
+	t.Run("Mode", func(t *testing.T) {
+		q := root.Func("query_with").(func(interface{}, string, string) (interface{}, error))
+		got, err := q(plan, "$.resource_changes[?@.type == 'aws_s3_bucket'].address", "jsonpath")
+		if want := []interface{}{"aws_s3_bucket.logs"}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v, %v", want, got, err)
+		}
+		got, err = q(plan, "$.nothing", "jsonpath")
+		if want := []interface{}{}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected an empty list, got %#v, %v", got, err)
+		}
+		if got, err := q(plan, "format_version", "jmespath"); err != nil || got != "1.2" {
+			t.Errorf("Expected 1.2, got %v, %v", got, err)
+		}
+		if _, err := q(plan, "$", "xpath"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument for an unknown mode, got %v", err)
+		}
+	})
+
+	// Test that query_file reads JSON and treats unreadable files as undefined
+	t.Run("File", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "plan.json")
+		if err := os.WriteFile(path, []byte(`{"resource_changes": [{"address": "a", "change": {"actions": ["delete"]}}, {"address": "b", "change": {"actions": ["update"]}}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		bad := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(bad, []byte(`{"unterminated": `), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		qf := root.Func("query_file").(func(string, string) (interface{}, error))
+		if got, err := qf(path, "length(resource_changes[?change.actions[0]=='delete'])"); err != nil || got != int64(1) {
+			t.Errorf("Expected 1, got %v, %v", got, err)
+		}
+		if got, err := qf(filepath.Join(dir, "missing.json"), "@"); got != nil || err != nil {
+			t.Errorf("Expected undefined for a missing file, got %v, %v", got, err)
+		}
+		if _, err := qf(bad, "@"); errorKind(err) != kindDecode {
+			t.Errorf("Expected a decode error, got %v", err)
+		}
+
+		qfw := root.Func("query_file_with").(func(string, string, string) (interface{}, error))
+		got, err := qfw(path, "$..address", "jsonpath")
+		if want := []interface{}{"a", "b"}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v, %v", want, got, err)
+		}
+	})
+}
@@ -0,0 +1,285 @@
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// funcSpec describes a function exposed to Sentinel, e.g. pd.getenv("HOME")
+type funcSpec struct {
+	Name        string
+	Args        []argSpec
+	Returns     string
+	Description string
+	Example     string
+
+	// Shape is a zero value of the Go type returned, used to document the
+	// fields of maps returned to Sentinel. Optional for primitive returns.
+	Shape interface{}
+
+	// Redact keeps the arguments out of the log, for functions that take
+	// secrets such as tokens.
+	Redact bool
+
+	// Cache is whether results may be reused when the cache is enabled,
+	// see cache.go. cacheByFile requires the first argument to be a path.
+	Cache cachePolicy
+
+	// Audit is the audit log source, "env" or "file", of what a call
+	// reads. A cache hit reads nothing, so the cache records the access
+	// itself, with the first argument as the target or "*" if there is none.
+	Audit string
+
+	// New returns the Go function the framework calls, bound to r. Its
+	// parameters must line up with Args.
+	New func(r *Root) interface{}
+}
+
+// argSpec describes a single function argument
+type argSpec struct {
+	Name string
+	Type string
+}
+
+// propSpec describes a property exposed to Sentinel, e.g. pd.now
+type propSpec struct {
+	Name        string
+	Returns     string
+	Description string
+	Example     string
+	Shape       interface{}
+	Cache       cachePolicy
+	Audit       string // as for funcSpec, recorded with the target "*"
+
+	Get func(r *Root) (interface{}, error)
+}
+
+// The registries are filled from init functions next to each
+// implementation, and are read-only once the plugin is serving.
+var (
+	funcRegistry = map[string]*funcSpec{}
+	propRegistry = map[string]*propSpec{}
+)
+
+func registerFunc(specs ...*funcSpec) {
+	for _, s := range specs {
+		if _, ok := funcRegistry[s.Name]; ok {
+			panic(fmt.Sprintf("function %q registered twice", s.Name))
+		}
+		funcRegistry[s.Name] = s
+	}
+}
+
+func registerProp(specs ...*propSpec) {
+	for _, s := range specs {
+		if _, ok := propRegistry[s.Name]; ok {
+			panic(fmt.Sprintf("property %q registered twice", s.Name))
+		}
+		propRegistry[s.Name] = s
+	}
+}
+
+// namespace groups the registry entries named "<name>.<key>" under a
+// single property, so that e.g. the "git.head" property is read as
+// pd.git.head and the "git.commit" function is called as pd.git.commit()
+type namespace struct {
+	r    *Root
+	name string
+}
+
+func (n *namespace) Get(key string) (interface{}, error) {
+	return n.r.Get(n.name + "." + key)
+}
+
+func (n *namespace) Func(key string) interface{} {
+	return n.r.Func(n.name + "." + key)
+}
+
+// Map returns every property of the namespace, for a policy that reads
+// pd.git as a whole. Undefined properties are left out.
+func (n *namespace) Map() (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	prefix := n.name + "."
+	for _, name := range sortedKeys(propRegistry) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		v, err := n.r.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		if !isNil(v) {
+			m[strings.TrimPrefix(name, prefix)] = v
+		}
+	}
+	return m, nil
+}
+
+// isNil reports whether v is nil or a typed nil, which Sentinel sees as
+// undefined or null
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// Signature renders the function the way it is called from a policy,
+// e.g. "getfile(path string) string"
+func (s *funcSpec) Signature() string {
+	args := make([]string, len(s.Args))
+	for i, a := range s.Args {
+		args[i] = a.Name + " " + a.Type
+	}
+	return fmt.Sprintf("%s(%s) %s", s.Name, strings.Join(args, ", "), s.Returns)
+}
+
+// Return structs
+type catalogEntry struct {
+	Name        string
+	Kind        string // function or property
+	Signature   string
+	Args        []argSpec
+	Returns     string
+	Shape       string
+	Description string
+	Example     string
+}
+
+// catalog lists every registered function and then every property, each
+// sorted by name.
+func catalog() []*catalogEntry {
+	entries := make([]*catalogEntry, 0, len(funcRegistry)+len(propRegistry))
+	for _, name := range sortedKeys(funcRegistry) {
+		s := funcRegistry[name]
+		args := s.Args
+		if args == nil {
+			args = []argSpec{}
+		}
+		entries = append(entries, &catalogEntry{
+			Name:        s.Name,
+			Kind:        "function",
+			Signature:   s.Signature(),
+			Args:        args,
+			Returns:     s.Returns,
+			Shape:       shapeOf(s.Shape, s.Returns),
+			Description: s.Description,
+			Example:     s.Example,
+		})
+	}
+	for _, name := range sortedKeys(propRegistry) {
+		s := propRegistry[name]
+		entries = append(entries, &catalogEntry{
+			Name:        s.Name,
+			Kind:        "property",
+			Signature:   s.Name + " " + s.Returns,
+			Args:        []argSpec{},
+			Returns:     s.Returns,
+			Shape:       shapeOf(s.Shape, s.Returns),
+			Description: s.Description,
+			Example:     s.Example,
+		})
+	}
+	return entries
+}
+
+// shapeOf describes the value Sentinel receives for a Go value of the
+// same type as v, e.g. {"name": string, "size": int}. Struct fields are
+// named the way the SDK encodes them. If v is nil, returns is used as is.
+func shapeOf(v interface{}, returns string) string {
+	if v == nil {
+		return returns
+	}
+	return shapeOfType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+func shapeOfType(t reflect.Type, seen map[reflect.Type]bool) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "[" + shapeOfType(t.Elem(), seen) + "]"
+	case reflect.Map:
+		return "{" + shapeOfType(t.Key(), seen) + ": " + shapeOfType(t.Elem(), seen) + "}"
+	case reflect.Struct:
+		if seen[t] {
+			return "{...}"
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := sentinelFieldName(f.Name)
+			if tag, ok := f.Tag.Lookup("sentinel"); ok {
+				if tag == "" {
+					continue
+				}
+				name = tag
+			}
+			fields = append(fields, fmt.Sprintf("%q: %s", name, shapeOfType(f.Type, seen)))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return "any"
+}
+
+// sentinelFieldName converts a Go field name the same way the SDK's
+// encoding does: "GitCommit" becomes "git_commit"
+func sentinelFieldName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	registerProp(&propSpec{
+		Name:        "functions",
+		Returns:     "list(map)",
+		Description: "Catalog of every function and property the plugin provides, with argument names and types.",
+		Example:     `pd.functions`,
+		Shape:       []*catalogEntry(nil),
+		Get: func(r *Root) (interface{}, error) {
+			return catalog(), nil
+		},
+	})
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	root := &Root{}
+
+	// Test that every function's declared arguments match its Go signature
+	t.Run("ArgsMatchSignatures", func(t *testing.T) {
+		for name, s := range funcRegistry {
+			fn := root.Func(name)
+			if fn == nil {
+				t.Fatalf("%s: Func should not return nil for a registered function", name)
+			}
+
+			typ := reflect.TypeOf(fn)
+			if typ.Kind() != reflect.Func {
+				t.Fatalf("%s: Func should return a function, got %s", name, typ)
+			}
+			if typ.NumIn() != len(s.Args) {
+				t.Errorf("%s: declares %d arguments, function takes %d", name, len(s.Args), typ.NumIn())
+			}
+		}
+	})
+
+	// Test that every entry is documented
+	t.Run("EntriesHaveDescriptions", func(t *testing.T) {
+		for _, e := range catalog() {
+			if e.Description == "" || e.Returns == "" {
+				t.Errorf("%s %s: missing description or return type", e.Kind, e.Name)
+			}
+		}
+	})
+
+	// Test that unknown keys are undefined
+	t.Run("UnknownKeysReturnNil", func(t *testing.T) {
+		if root.Func("does_not_exist") != nil {
+			t.Error("Func should return nil for unknown functions")
+		}
+		result, err := root.Get("does_not_exist")
+		if result != nil || err != nil {
+			t.Errorf("Expected nil, nil for unknown property, got %v, %v", result, err)
+		}
+	})
+
+	// Test that the functions property lists the whole catalog
+	t.Run("FunctionsPropertyListsCatalog", func(t *testing.T) {
+		result, err := root.Get("functions")
+		if err != nil {
+			t.Fatalf("functions property should not return error: %v", err)
+		}
+
+		entries, ok := result.([]*catalogEntry)
+		if !ok {
+			t.Fatal("functions should return a list of catalog entries")
+		}
+		if len(entries) != len(funcRegistry)+len(propRegistry) {
+			t.Errorf("Expected %d entries, got %d", len(funcRegistry)+len(propRegistry), len(entries))
+		}
+
+		found := map[string]string{}
+		for _, e := range entries {
+			found[e.Name] = e.Kind
+		}
+		if found["getfile"] != "function" || found["pwd"] != "property" || found["functions"] != "property" {
+			t.Errorf("Unexpected catalog contents: %v", found)
+		}
+	})
+
+	// Test that signatures are rendered as called from a policy
+	t.Run("RendersSignatures", func(t *testing.T) {
+		if got := funcRegistry["archive_read"].Signature(); got != "archive_read(path string, member string) string" {
+			t.Errorf("Unexpected signature %s", got)
+		}
+	})
+
+	// Test that return shapes use the SDK's field names
+	t.Run("RendersShapes", func(t *testing.T) {
+		want := `[{"name": string, "type": string, "size": int, "mode": string, "modified": string}]`
+		if got := shapeOf([]*archiveEntry(nil), "list(map)"); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+		if got := shapeOf((*versionInfo)(nil), "map"); !strings.Contains(got, `"git_commit": string`) || !strings.Contains(got, `"sdk_version": string`) {
+			t.Errorf("Unexpected version shape %s", got)
+		}
+		if got := shapeOf(nil, "string"); got != "string" {
+			t.Errorf("Expected string, got %s", got)
+		}
+	})
+}
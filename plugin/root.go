@@ -1,23 +1,46 @@
 package plugin
 
 import (
+	"errors"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/hashicorp/go-hclog"
 	sdk "github.com/hashicorp/sentinel-sdk"
 	"github.com/hashicorp/sentinel-sdk/framework"
 )
 
 type Root struct {
+	config   *config
+	logger   hclog.Logger
+	auditLog *auditLog
+	cache    *callCache
+
+	metrics     *metrics
+	metricsOnce sync.Once
 }
 
 func New() sdk.Plugin {
-	return &framework.Plugin{
-		Root: &Root{},
+	root := &Root{}
+	return &closingPlugin{
+		Plugin: &framework.Plugin{Root: root},
+		root:   root,
 	}
 }
 
+// closingPlugin passes Close from the Sentinel host through to Root, which
+// framework.Plugin does not do on its own
+type closingPlugin struct {
+	*framework.Plugin
+	root *Root
+}
+
+func (p *closingPlugin) Close() error {
+	return p.root.Close()
+}
+
 // Return structs
 type testTime struct {
 	Time    time.Time
@@ -28,85 +51,195 @@ type envVars struct {
 	All []string
 }
 
-// This is where we can add new functions
+// Functions are looked up in the registry, see registry.go. New functions
+// are added with registerFunc in an init function next to their code.
 // Example in Sentinel, key == "getallenvs":
 //
 // import "plugin-demo" as pd
 // pd.getallenvs()
 func (r *Root) Func(key string) interface{} {
-	switch key {
-	// Get all environment variables, return a map
-	case "getallenvs":
-		return func() interface{} {
-			envMap := make(map[string]string)
-			for _, env := range os.Environ() {
-				parts := strings.SplitN(env, "=", 2)
-				if len(parts) == 2 {
-					envMap[parts[0]] = parts[1]
-				}
-			}
-			return &envMap
-		}
-	// Get a specific environment variable, return its value or empty if not found
-	case "getenv":
-		return func(key string) interface{} {
-			value := os.Getenv(key)
-			return &value
-		}
-	case "getfile":
-		return func(path string) interface{} {
-			contents, err := os.ReadFile(path)
-			if err != nil {
-				return nil // File not found or inaccessible
-			}
-			contentsStr := string(contents)
-			return &contentsStr
-		}
-	// Test function, return current time and a message
-	case "test":
-		return func() interface{} {
-			return &testTime{Time: time.Now(), Message: "Test message"}
-		}
+	if s, ok := funcRegistry[key]; ok {
+		return r.instrumentFunc(s, r.cacheFunc(s.Name, s.Cache, s.Audit, s.New(r)))
 	}
 	return nil
 }
 
-// This is where we can add new properties
+// Properties are looked up in the registry, see registry.go. New properties
+// are added with registerProp in an init function next to their code.
 // Example in Sentinel, key == "now":
 //
 // import "plugin-demo" as pd
 // pd.now
 func (r *Root) Get(key string) (interface{}, error) {
-	switch key {
-	// Get all environment variables as a property, return a map
-	case "envs":
-		envMap := make(map[string]string)
-		for _, env := range os.Environ() {
-			parts := strings.SplitN(env, "=", 2)
-			if len(parts) == 2 {
-				envMap[parts[0]] = parts[1]
-			}
-		}
-		return envMap, nil
-	// Get current time as a property
-	case "now":
-		return &testTime{Time: time.Now()}, nil
-	// Get current working directory as a property
-	case "pwd":
-		dir, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		return &dir, nil
+	if s, ok := propRegistry[key]; ok {
+		return r.instrumentGet(s.Name, r.cacheGet(s.Name, s.Cache, s.Audit, s.Get))
 	}
 	return nil, nil
 }
 
-// Required Implementation - not used
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "getallenvs",
+			Returns:     "map(string)",
+			Description: "All environment variables of the plugin process as a map.",
+			Example:     `pd.getallenvs()["HOME"]`,
+			Shape:       map[string]string(nil),
+			Cache:       cacheByArgs,
+			Audit:       "env",
+			New: func(r *Root) interface{} {
+				return func() interface{} {
+					r.audit("env", "*", nil)
+					envMap := environMap()
+					return &envMap
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "getenv",
+			Args:        []argSpec{{"key", "string"}},
+			Returns:     "string",
+			Description: "Value of a single environment variable, or an empty string if it is not set.",
+			Example:     `pd.getenv("HOME")`,
+			Cache:       cacheByArgs,
+			Audit:       "env",
+			New: func(r *Root) interface{} {
+				return func(key string) interface{} {
+					r.audit("env", key, nil)
+					value := os.Getenv(key)
+					return &value
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "getfile",
+			Args:        []argSpec{{"path", "string"}},
+			Returns:     "string",
+			Description: "Contents of a file, or undefined if it cannot be read. Fails with too_large or timeout past max_file_bytes or io_timeout.",
+			Example:     `pd.getfile("./plan.json")`,
+			Cache:       cacheByFile,
+			Audit:       "file",
+			New: func(r *Root) interface{} {
+				return func(path string) (interface{}, error) {
+					return r.fileResult(r.readFile(path))
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "test",
+			Returns:     "map",
+			Description: "Test function returning the current time and a message.",
+			Example:     `pd.test().message`,
+			Shape:       (*testTime)(nil),
+			New: func(r *Root) interface{} {
+				return func() interface{} {
+					return &testTime{Time: time.Now(), Message: "Test message"}
+				}
+			},
+		},
+	)
+
+	registerProp(
+		&propSpec{
+			Name:        "envs",
+			Returns:     "map(string)",
+			Description: "All environment variables of the plugin process as a map.",
+			Example:     `pd.envs["HOME"]`,
+			Shape:       map[string]string(nil),
+			Cache:       cacheByArgs,
+			Audit:       "env",
+			Get: func(r *Root) (interface{}, error) {
+				r.audit("env", "*", nil)
+				return environMap(), nil
+			},
+		},
+		&propSpec{
+			Name:        "now",
+			Returns:     "map",
+			Description: "The current time.",
+			Example:     `pd.now`,
+			Shape:       (*testTime)(nil),
+			Get: func(r *Root) (interface{}, error) {
+				return &testTime{Time: time.Now()}, nil
+			},
+		},
+		&propSpec{
+			Name:        "pwd",
+			Returns:     "string",
+			Description: "Current working directory of the plugin process.",
+			Example:     `pd.pwd`,
+			Get: func(r *Root) (interface{}, error) {
+				dir, err := os.Getwd()
+				if err != nil {
+					return nil, err
+				}
+				return &dir, nil
+			},
+		},
+	)
+}
+
+// environMap splits os.Environ into a map, keeping any "=" in values
+func environMap() map[string]string {
+	envMap := make(map[string]string)
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			envMap[parts[0]] = parts[1]
+		}
+	}
+	return envMap
+}
+
+// Apply the config block from sentinel.hcl, see config.go for the settings
 func (r *Root) Configure(m map[string]interface{}) error {
+	c, err := parseConfig(m)
+	if err != nil {
+		return err
+	}
+	r.config = c
+	r.logger = newLogger(c)
+
+	if r.auditLog != nil {
+		r.auditLog.Close()
+		r.auditLog = nil
+	}
+	if c.AuditFile != "" {
+		r.auditLog = newAuditLog(c.AuditFile, c.AuditMaxBytes, c.AuditMaxBackups)
+	}
+
+	r.cache = nil
+	if c.CacheSize > 0 {
+		r.cache = newCallCache(c.CacheSize)
+	}
 	return nil
 }
 
+// conf returns the active config, or the defaults if Configure was never called
+func (r *Root) conf() *config {
+	if r.config == nil {
+		return defaultConfig()
+	}
+	return r.config
+}
+
+// Close flushes the metrics file and closes the audit log when the plugin
+// shuts down. The metrics file is replaced atomically, so with several
+// instances sharing a config it holds the counters of the last to close.
+func (r *Root) Close() error {
+	var errs []error
+	if c := r.conf(); c.MetricsFile != "" {
+		if err := r.stats().writeTextfile(c.MetricsFile); err != nil {
+			r.log().Error("failed to write metrics file", "path", c.MetricsFile, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if r.auditLog != nil {
+		errs = append(errs, r.auditLog.Close())
+	}
+	return errors.Join(errs...)
+}
+
 // Required Implementation - not used
 func (r *Root) New(data map[string]interface{}) (framework.Namespace, error) {
 	return nil, nil
@@ -286,13 +286,13 @@ func TestGetFile(t *testing.T) {
 		}
 
 		// Call the function
-		callable, ok := fn.(func(string) interface{})
+		callable, ok := fn.(func(string) (interface{}, error))
 		if !ok {
 			t.Fatal("getfile should return a callable function that takes a string parameter")
 		}
 
 		// Test with a non-existent file (should return nil)
-		result := callable("/non/existent/file")
+		result, _ := callable("/non/existent/file")
 		if result != nil {
 			t.Log("getfile returns nil for non-existent files, which is expected")
 		}
@@ -312,8 +312,8 @@ func TestGetFile(t *testing.T) {
 		defer os.Remove(tempFile)
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
-		result := callable(tempFile)
+		callable := fn.(func(string) (interface{}, error))
+		result, _ := callable(tempFile)
 
 		if result == nil {
 			t.Fatal("getfile should not return nil for existing file")
@@ -334,8 +334,8 @@ func TestGetFile(t *testing.T) {
 		nonExistentFile := "/absolutely/non/existent/file/path/12345.txt"
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
-		result := callable(nonExistentFile)
+		callable := fn.(func(string) (interface{}, error))
+		result, _ := callable(nonExistentFile)
 
 		if result != nil {
 			t.Error("getfile should return nil for non-existent files")
@@ -355,8 +355,8 @@ func TestGetFile(t *testing.T) {
 		defer os.Remove(tempFile)
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
-		result := callable(tempFile)
+		callable := fn.(func(string) (interface{}, error))
+		result, _ := callable(tempFile)
 
 		if result == nil {
 			t.Fatal("getfile should not return nil for existing empty file")
@@ -386,8 +386,8 @@ func TestGetFile(t *testing.T) {
 		defer os.Remove(tempFile)
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
-		result := callable(tempFile)
+		callable := fn.(func(string) (interface{}, error))
+		result, _ := callable(tempFile)
 
 		if result == nil {
 			t.Fatal("getfile should not return nil for existing binary file")
@@ -410,8 +410,8 @@ func TestGetFile(t *testing.T) {
 		tempDir := os.TempDir()
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
-		result := callable(tempDir)
+		callable := fn.(func(string) (interface{}, error))
+		result, _ := callable(tempDir)
 
 		if result != nil {
 			t.Error("getfile should return nil when trying to read a directory")
@@ -432,14 +432,14 @@ func TestGetFile(t *testing.T) {
 		defer os.Remove(tempFile)
 
 		fn := root.Func("getfile")
-		callable := fn.(func(string) interface{})
+		callable := fn.(func(string) (interface{}, error))
 
 		// First call
-		result1 := callable(tempFile)
+		result1, _ := callable(tempFile)
 		contentPtr1 := result1.(*string)
 
 		// Second call
-		result2 := callable(tempFile)
+		result2, _ := callable(tempFile)
 		contentPtr2 := result2.(*string)
 
 		if *contentPtr1 != *contentPtr2 {
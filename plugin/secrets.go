@@ -0,0 +1,366 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// secretRule matches one kind of credential on a single line
+type secretRule struct {
+	ID string
+	re *regexp.Regexp
+
+	// minEntropy, if set, drops matches whose Shannon entropy in bits per
+	// character is lower, to tell random keys from ordinary words
+	minEntropy float64
+
+	// keep leaves the match unredacted in snippets, for matches that are
+	// markers rather than the secret itself
+	keep bool
+}
+
+// Built-in rules. Rules are applied in order, the specific ones first,
+// then any secret_rules from the config, then the catch-all entropy rule;
+// a match overlapping an earlier finding on the same line is dropped.
+var (
+	builtinSecretRules = []*secretRule{
+		{ID: "aws_access_key_id", re: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+		{ID: "aws_secret_access_key", re: regexp.MustCompile(`(?i)aws_?secret_?access_?key\W{0,4}[A-Za-z0-9/+=]{40}\b`)},
+		{ID: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+		{ID: "private_key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`), keep: true},
+	}
+	entropySecretRule = &secretRule{ID: "high_entropy_string", re: regexp.MustCompile(`[A-Za-z0-9+/_\-]{32,}={0,2}`), minEntropy: 4.5}
+)
+
+// Lock files are full of checksums, which look like random keys, so the
+// entropy rule is not applied to them
+var checksumFiles = map[string]bool{
+	"go.sum":              true,
+	".terraform.lock.hcl": true,
+	"package-lock.json":   true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"Cargo.lock":          true,
+	"poetry.lock":         true,
+	"Gemfile.lock":        true,
+}
+
+// Longest snippet returned with a finding
+const snippetMaxLen = 120
+
+// Most files a single scan collects, so a pattern such as "/" fails
+// instead of walking the whole filesystem
+const scanMaxFiles = 100000
+
+// Return structs
+type secretScan struct {
+	Findings     []*secretFinding
+	FilesScanned int
+	Skipped      []*skippedFile
+}
+
+type secretFinding struct {
+	RuleID  string `sentinel:"rule_id"`
+	File    string
+	Line    int
+	Column  int
+	Snippet string // the line with the secret redacted
+}
+
+type skippedFile struct {
+	File      string
+	Error     string
+	ErrorKind string
+}
+
+// secretRules returns the rules to apply to path, in order
+func (r *Root) secretRules(path string) []*secretRule {
+	rules := append([]*secretRule{}, builtinSecretRules...)
+	rules = append(rules, r.conf().SecretRules...)
+	if checksumFiles[filepath.Base(path)] {
+		return rules
+	}
+	return append(rules, entropySecretRule)
+}
+
+// scanSecrets scans every file matched by pattern: a file, a directory
+// (walked recursively, skipping .git) or a glob. Binary files are
+// skipped; files that cannot be read are listed in Skipped so a secret
+// cannot hide in a file the scan never saw.
+func (r *Root) scanSecrets(pattern string) (*secretScan, error) {
+	type expansion struct {
+		files   []string
+		skipped []*skippedFile
+	}
+	timeout := r.conf().IOTimeout
+	deadline := time.Now().Add(timeout)
+	e, err := withTimeout(timeout, pattern, func() (*expansion, error) {
+		files, skipped, err := expandScanPaths(pattern, deadline, scanMaxFiles)
+		return &expansion{files, skipped}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	files, skipped := e.files, e.skipped
+	res := &secretScan{Findings: []*secretFinding{}, Skipped: append([]*skippedFile{}, skipped...)}
+
+	var mu sync.Mutex
+	r.forEachPath(files, func(path string) {
+		data, err := r.readFile(path)
+		if err != nil {
+			mu.Lock()
+			res.Skipped = append(res.Skipped, newSkippedFile(path, err))
+			mu.Unlock()
+			return
+		}
+		if isBinary(data) {
+			return
+		}
+
+		findings := scanData(path, data, r.secretRules(path))
+		mu.Lock()
+		res.FilesScanned++
+		res.Findings = append(res.Findings, findings...)
+		mu.Unlock()
+	})
+
+	sort.Slice(res.Findings, func(i, j int) bool {
+		a, b := res.Findings[i], res.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	sort.Slice(res.Skipped, func(i, j int) bool {
+		return res.Skipped[i].File < res.Skipped[j].File
+	})
+	return res, nil
+}
+
+// expandScanPaths resolves pattern into the regular files to scan, and
+// the paths that could not be walked. The walk fails with a timeout
+// error once deadline passes, and with too_large beyond maxFiles files.
+func expandScanPaths(pattern string, deadline time.Time, maxFiles int) ([]string, []*skippedFile, error) {
+	roots := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, &kindError{Kind: kindInvalidArgument, Path: pattern, Err: err}
+		}
+		roots = matches
+	}
+
+	var files []string
+	var skipped []*skippedFile
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if time.Now().After(deadline) {
+				return &kindError{Kind: kindTimeout, Path: pattern, Err: errors.New("walk did not finish within io_timeout")}
+			}
+			if err != nil {
+				skipped = append(skipped, newSkippedFile(path, err))
+				return nil
+			}
+			if d.IsDir() && d.Name() == ".git" && path != root {
+				return filepath.SkipDir
+			}
+			if d.Type().IsRegular() {
+				if len(files) == maxFiles {
+					return &kindError{Kind: kindTooLarge, Path: pattern, Err: fmt.Errorf("matches more than %d files", maxFiles)}
+				}
+				files = append(files, path)
+			}
+			return nil
+		})
+		if errorKind(err) != "" {
+			return nil, nil, err
+		}
+		if err != nil {
+			skipped = append(skipped, newSkippedFile(root, err))
+		}
+	}
+	return files, skipped, nil
+}
+
+func newSkippedFile(path string, err error) *skippedFile {
+	msg, kind := errorFields(err)
+	return &skippedFile{File: path, Error: msg, ErrorKind: kind}
+}
+
+// isBinary reports whether data looks like a binary file, the same way
+// git does: a NUL byte in the first 8000 bytes
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// secretMatch is a finding's position in its line
+type secretMatch struct {
+	rule *secretRule
+	loc  []int
+}
+
+// scanData applies rules to every line of data. Every match on a line is
+// found before any snippet is built, so each snippet has all of them
+// redacted, not only its own.
+func scanData(path string, data []byte, rules []*secretRule) []*secretFinding {
+	var findings []*secretFinding
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		var matches []*secretMatch
+		for _, rule := range rules {
+			for _, loc := range rule.re.FindAllStringIndex(line, -1) {
+				match := line[loc[0]:loc[1]]
+				if rule.minEntropy > 0 && shannonEntropy(match) < rule.minEntropy {
+					continue
+				}
+				if overlapsAny(loc, matches) {
+					continue
+				}
+				matches = append(matches, &secretMatch{rule, loc})
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		redacted, locs := redactLine(line, matches)
+		for j, m := range matches {
+			findings = append(findings, &secretFinding{
+				RuleID:  m.rule.ID,
+				File:    path,
+				Line:    i + 1,
+				Column:  m.loc[0] + 1,
+				Snippet: snippetAround(redacted, locs[j]),
+			})
+		}
+	}
+	return findings
+}
+
+func overlapsAny(loc []int, found []*secretMatch) bool {
+	for _, f := range found {
+		if loc[0] < f.loc[1] && f.loc[0] < loc[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// redactLine masks every match in line, keeping the first four
+// characters of each so the finding can be recognized. It returns the
+// masked line and where each match ends up in it.
+func redactLine(line string, matches []*secretMatch) (string, [][]int) {
+	order := make([]int, len(matches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return matches[order[i]].loc[0] < matches[order[j]].loc[0]
+	})
+
+	var b strings.Builder
+	locs := make([][]int, len(matches))
+	prev := 0
+	for _, i := range order {
+		m := matches[i]
+		b.WriteString(line[prev:m.loc[0]])
+		start := b.Len()
+		b.WriteString(maskSecret(line[m.loc[0]:m.loc[1]], m.rule.keep))
+		locs[i] = []int{start, b.Len()}
+		prev = m.loc[1]
+	}
+	b.WriteString(line[prev:])
+	return b.String(), locs
+}
+
+// maskSecret replaces all but the first four characters of a match with
+// a fixed-length mask, or all of it when it is short
+func maskSecret(match string, keep bool) string {
+	if keep {
+		return match
+	}
+	runes := []rune(match)
+	visible := 4
+	if len(runes) < 8 {
+		visible = 0
+	}
+	return string(runes[:visible]) + strings.Repeat("*", 8)
+}
+
+// snippetAround trims line to snippetMaxLen around the match at loc,
+// cutting on rune boundaries
+func snippetAround(line string, loc []int) string {
+	match := line[loc[0]:loc[1]]
+	before, after := line[:loc[0]], line[loc[1]:]
+
+	room := snippetMaxLen - len(match)
+	if room < 0 {
+		room = 0
+	}
+	if len(before) > room/2 {
+		cut := len(before) - room/2
+		for cut < len(before) && !utf8.RuneStart(before[cut]) {
+			cut++
+		}
+		before = "..." + before[cut:]
+	}
+	if len(after) > room/2 {
+		cut := room / 2
+		for cut > 0 && !utf8.RuneStart(after[cut]) {
+			cut--
+		}
+		after = after[:cut] + "..."
+	}
+	return strings.TrimSpace(before + match + after)
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "scan_secrets",
+		Args:        []argSpec{{"path_or_glob", "string"}},
+		Returns:     "map",
+		Description: "Scans a file, a directory (recursively) or a glob for credentials: AWS keys, GitHub tokens, private keys, high-entropy strings and any secret_rules. Returns findings with rule ID, file, line and a redacted snippet, and the files that could not be scanned.",
+		Example:     `length(pd.scan_secrets("./").findings) is 0`,
+		Shape:       (*secretScan)(nil),
+		New: func(r *Root) interface{} {
+			return func(pattern string) (interface{}, error) {
+				res, err := r.scanSecrets(pattern)
+				if err != nil {
+					return nil, err
+				}
+				return res, nil
+			}
+		},
+	})
+}
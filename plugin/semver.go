@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"fmt"
+
+	goversion "github.com/hashicorp/go-version"
+)
+
+// parseSemver parses a version such as "1.2.3", "v1.2" or "1.3.0-beta1"
+func parseSemver(s string) (*goversion.Version, error) {
+	v, err := goversion.NewVersion(s)
+	if err != nil {
+		return nil, &kindError{Kind: kindInvalidArgument, Err: err}
+	}
+	return v, nil
+}
+
+// parseSemverConstraint parses a Terraform-style constraint such as
+// "~> 5.0, != 5.3.1". An empty constraint allows any release, but no
+// prerelease, the same as ">= 0.0.0".
+func parseSemverConstraint(s string) (goversion.Constraints, error) {
+	if s == "" {
+		s = ">= 0.0.0"
+	}
+	c, err := goversion.NewConstraint(s)
+	if err != nil {
+		return nil, &kindError{Kind: kindInvalidArgument, Err: err}
+	}
+	return c, nil
+}
+
+// semverSatisfies checks version against constraint. As in Terraform, a
+// prerelease only satisfies a constraint that names a prerelease of the
+// same major, minor and patch version.
+func semverSatisfies(version, constraint string) (bool, error) {
+	v, err := parseSemver(version)
+	if err != nil {
+		return false, err
+	}
+	c, err := parseSemverConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+// semverLatest returns the highest of versions that satisfies
+// constraint, as it was written in the list, or "" if none does
+func semverLatest(versions []interface{}, constraint string) (string, error) {
+	c, err := parseSemverConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *goversion.Version
+	var latestRaw string
+	for i, item := range versions {
+		s, ok := item.(string)
+		if !ok {
+			return "", &kindError{Kind: kindInvalidArgument, Err: fmt.Errorf("[%d]: expected a version string, got %T", i, item)}
+		}
+		v, err := parseSemver(s)
+		if err != nil {
+			return "", err
+		}
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest, latestRaw = v, s
+		}
+	}
+	return latestRaw, nil
+}
+
+func init() {
+	registerFunc(
+		&funcSpec{
+			Name:        "semver_compare",
+			Args:        []argSpec{{"a", "string"}, {"b", "string"}},
+			Returns:     "int",
+			Description: "Compares two versions with semantic versioning precedence, prereleases sorting before their release: -1 if a is lower, 0 if equal, 1 if higher. Fails with invalid_argument if either is not a version.",
+			Example:     `pd.semver_compare("1.10.0", "1.9.3") is 1`,
+			New: func(r *Root) interface{} {
+				return func(a, b string) (interface{}, error) {
+					va, err := parseSemver(a)
+					if err != nil {
+						return nil, err
+					}
+					vb, err := parseSemver(b)
+					if err != nil {
+						return nil, err
+					}
+					return va.Compare(vb), nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "semver_satisfies",
+			Args:        []argSpec{{"version", "string"}, {"constraint", "string"}},
+			Returns:     "bool",
+			Description: "Whether a version satisfies a Terraform-style constraint such as \"~> 5.0, != 5.3.1\". Prereleases only satisfy constraints naming a prerelease of the same version. Fails with invalid_argument on a malformed version or constraint.",
+			Example:     `pd.semver_satisfies("5.31.0", "~> 5.0, != 5.3.1")`,
+			New: func(r *Root) interface{} {
+				return func(version, constraint string) (interface{}, error) {
+					ok, err := semverSatisfies(version, constraint)
+					if err != nil {
+						return nil, err
+					}
+					return ok, nil
+				}
+			},
+		},
+		&funcSpec{
+			Name:        "semver_latest",
+			Args:        []argSpec{{"versions", "list(string)"}, {"constraint", "string"}},
+			Returns:     "string",
+			Description: "The highest version in the list that satisfies the constraint, as written in the list, or undefined if none does. An empty constraint allows any release but no prerelease.",
+			Example:     `pd.semver_latest(["5.30.0", "5.31.0", "6.0.0-beta1"], "~> 5.0")`,
+			New: func(r *Root) interface{} {
+				return func(versions []interface{}, constraint string) (interface{}, error) {
+					latest, err := semverLatest(versions, constraint)
+					if err != nil || latest == "" {
+						return nil, err
+					}
+					return latest, nil
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestSemver(t *testing.T) {
+	root := &Root{}
+	root.Configure(map[string]interface{}{"log_level": "off"})
+
+	// Test that versions compare by precedence, not as strings
+	t.Run("Compare", func(t *testing.T) {
+		compare := root.Func("semver_compare").(func(string, string) (interface{}, error))
+		for _, c := range []struct {
+			a, b string
+			want int
+		}{
+			{"1.10.0", "1.9.3", 1},
+			{"1.2", "1.2.0", 0},
+			{"v1.2.3", "1.2.3", 0},
+			{"1.3.0-beta1", "1.3.0", -1},
+			{"1.3.0-alpha", "1.3.0-beta", -1},
+			{"1.3.0+build5", "1.3.0", 0},
+		} {
+			got, err := compare(c.a, c.b)
+			if err != nil || got != c.want {
+				t.Errorf("compare(%s, %s): expected %d, got %v, %v", c.a, c.b, c.want, got, err)
+			}
+		}
+
+		if _, err := compare("1.2.x", "1.2.0"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument, got %v", err)
+		}
+	})
+
+	// Test Terraform constraint semantics, including prereleases
+	t.Run("Satisfies", func(t *testing.T) {
+		satisfies := root.Func("semver_satisfies").(func(string, string) (interface{}, error))
+		for _, c := range []struct {
+			version, constraint string
+			want                bool
+		}{
+			{"5.31.0", "~> 5.0, != 5.3.1", true},
+			{"5.3.1", "~> 5.0, != 5.3.1", false},
+			{"6.0.0", "~> 5.0", false},
+			{"5.0.9", "~> 5.0.1", true},
+			{"5.1.0", "~> 5.0.1", false},
+			{"1.4.0", ">= 1.2, < 2.0", true},
+			{"1.3.0-beta1", ">= 1.0", false},
+			{"1.3.0-beta2", ">= 1.3.0-beta1", true},
+			{"1.4.0-beta1", ">= 1.3.0-beta1", false},
+			{"2.0.0", "", true},
+			{"2.0.0-rc1", "", false},
+		} {
+			got, err := satisfies(c.version, c.constraint)
+			if err != nil || got != c.want {
+				t.Errorf("satisfies(%s, %q): expected %v, got %v, %v", c.version, c.constraint, c.want, got, err)
+			}
+		}
+
+		if _, err := satisfies("1.0.0", "~> banana"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument, got %v", err)
+		}
+	})
+
+	// Test that latest picks the highest satisfying version as written
+	t.Run("Latest", func(t *testing.T) {
+		latest := root.Func("semver_latest").(func([]interface{}, string) (interface{}, error))
+		versions := []interface{}{"v5.30.0", "5.31.0", "5.9.0", "6.0.0-beta1", "4.67.0"}
+		for constraint, want := range map[string]interface{}{
+			"~> 5.0":         "5.31.0",
+			"< 5.10":         "5.9.0",
+			"":               "5.31.0",
+			">= 6.0.0-beta1": "6.0.0-beta1",
+			"= 5.30.0":       "v5.30.0",
+			"> 7":            nil,
+		} {
+			got, err := latest(versions, constraint)
+			if err != nil || got != want {
+				t.Errorf("latest(%q): expected %v, got %v, %v", constraint, want, got, err)
+			}
+		}
+
+		if _, err := latest([]interface{}{"1.0.0", int64(2)}, ""); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument for a number, got %v", err)
+		}
+		if _, err := latest([]interface{}{"nope"}, ""); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected invalid_argument for a bad version, got %v", err)
+		}
+	})
+}
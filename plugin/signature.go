@@ -0,0 +1,224 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Signature schemes supported by verify_signature
+const (
+	schemeEd25519  = "ed25519"
+	schemeMinisign = "minisign"
+	schemeCosign   = "cosign"
+)
+
+// Minisign signature algorithms: Ed signs the file itself, ED (the
+// default since minisign 0.10) signs its BLAKE2b-512 hash
+var (
+	minisignAlgPure   = []byte("Ed")
+	minisignAlgHashed = []byte("ED")
+)
+
+// verifySignature checks that sig is a valid detached signature of data
+// under the public key keyData. A signature that is malformed or does not
+// match returns false, while a key that cannot be used fails with an
+// invalid_key error since that is a configuration problem, not a bad
+// artifact.
+func verifySignature(data, sig, keyData []byte, scheme string) (bool, error) {
+	switch strings.ToLower(scheme) {
+	case schemeEd25519:
+		key, err := parsePEMPublicKey(keyData)
+		if err != nil {
+			return false, err
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return false, invalidKey(fmt.Errorf("expected an ed25519 key, got %T", key))
+		}
+		raw, ok := decodeSignature(sig, ed25519.SignatureSize)
+		return ok && ed25519.Verify(pub, data, raw), nil
+
+	case schemeCosign:
+		key, err := parsePEMPublicKey(keyData)
+		if err != nil {
+			return false, err
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return false, invalidKey(fmt.Errorf("expected an ECDSA P-256 key, got %T", key))
+		}
+		raw, ok := decodeSignature(sig, 0)
+		digest := sha256.Sum256(data)
+		return ok && ecdsa.VerifyASN1(pub, digest[:], raw), nil
+
+	case schemeMinisign:
+		keyID, pub, err := parseMinisignKey(keyData)
+		if err != nil {
+			return false, err
+		}
+		return verifyMinisign(data, sig, keyID, pub), nil
+	}
+
+	return false, &kindError{
+		Kind: kindInvalidArgument,
+		Err:  fmt.Errorf("unknown signature scheme %q, expected ed25519, minisign or cosign", scheme),
+	}
+}
+
+// parsePEMPublicKey parses a PKIX public key in a PEM "PUBLIC KEY" block,
+// the format written by openssl and cosign
+func parsePEMPublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, invalidKey(errors.New("no PEM block found"))
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, invalidKey(err)
+	}
+	return key, nil
+}
+
+// decodeSignature accepts a signature either raw or base64 encoded, as
+// written by cosign sign-blob. If size is not zero the decoded signature
+// must be exactly that long.
+func decodeSignature(sig []byte, size int) ([]byte, bool) {
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig))); err == nil {
+		sig = decoded
+	}
+	return sig, size == 0 || len(sig) == size
+}
+
+// parseMinisignKey parses a minisign public key, either the .pub file
+// with its untrusted comment or just the base64 line
+func parseMinisignKey(data []byte) ([]byte, ed25519.PublicKey, error) {
+	lines := minisignLines(data)
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil, nil, invalidKey(errors.New("empty minisign public key"))
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, nil, invalidKey(err)
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize || !bytes.Equal(raw[:2], minisignAlgPure) {
+		return nil, nil, invalidKey(errors.New("not a minisign ed25519 public key"))
+	}
+	return raw[2:10], ed25519.PublicKey(raw[10:]), nil
+}
+
+// verifyMinisign checks a minisign signature file: the signature of the
+// data (or its BLAKE2b-512 hash) by the key with keyID, and the global
+// signature binding the trusted comment to it.
+func verifyMinisign(data, sig, keyID []byte, pub ed25519.PublicKey) bool {
+	lines := minisignLines(sig)
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return false
+	}
+	alg, id, signature := raw[:2], raw[2:10], raw[10:]
+	if !bytes.Equal(id, keyID) {
+		return false
+	}
+
+	switch {
+	case bytes.Equal(alg, minisignAlgHashed):
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	case !bytes.Equal(alg, minisignAlgPure):
+		return false
+	}
+	if !ed25519.Verify(pub, data, signature) {
+		return false
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return false
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	return ed25519.Verify(pub, append(append([]byte{}, signature...), trusted...), global)
+}
+
+func minisignLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func invalidKey(err error) error {
+	return &kindError{Kind: kindInvalidKey, Err: err}
+}
+
+// readPublicKey returns the key named by ref: a key configured in
+// signing_keys, given inline or as a path, or else the path of a key file
+func (r *Root) readPublicKey(ref string) ([]byte, error) {
+	if key, ok := r.conf().SigningKeys[ref]; ok {
+		if strings.Contains(key, "\n") {
+			return []byte(key), nil
+		}
+		if _, _, err := parseMinisignKey([]byte(key)); err == nil {
+			return []byte(key), nil
+		}
+		ref = key
+	}
+	return r.readFile(ref)
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "verify_signature",
+		Args:        []argSpec{{"file", "string"}, {"sigfile", "string"}, {"pubkey", "string"}, {"scheme", "string"}},
+		Returns:     "bool",
+		Description: "Whether sigfile is a valid detached signature of file. scheme is ed25519, minisign or cosign (ECDSA P-256); pubkey is a key name from signing_keys or a key file path.",
+		Example:     `pd.verify_signature("./plugin.zip", "./plugin.zip.minisig", "release", "minisign")`,
+		New: func(r *Root) interface{} {
+			return func(file, sigfile, pubkey, scheme string) (interface{}, error) {
+				key, err := r.readPublicKey(pubkey)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+				sig, err := r.readFile(sigfile)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+				data, err := r.readFile(file)
+				if err != nil {
+					return nil, r.fileError(err)
+				}
+
+				ok, err := verifySignature(data, sig, key, scheme)
+				if err != nil {
+					var ke *kindError
+					if errors.As(err, &ke) && ke.Kind == kindInvalidKey {
+						ke.Path = pubkey
+					}
+					return nil, err
+				}
+				return &ok, nil
+			}
+		},
+	})
+}
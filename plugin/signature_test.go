@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func pemPublicKey(t *testing.T, pub interface{}) []byte {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// minisignFiles returns a minisign public key file and a signature of data
+// using the given algorithm, Ed or ED
+func minisignFiles(pub ed25519.PublicKey, priv ed25519.PrivateKey, keyID []byte, alg string, data []byte) (string, string) {
+	key := append(append([]byte("Ed"), keyID...), pub...)
+	pubFile := "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(key) + "\n"
+
+	msg := data
+	if alg == "ED" {
+		sum := blake2b.Sum512(data)
+		msg = sum[:]
+	}
+	sig := ed25519.Sign(priv, msg)
+	trusted := "timestamp:1700000000\tfile:plugin.zip"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+
+	sigFile := "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(alg), keyID...), sig...)) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+	return pubFile, sigFile
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte("release bundle")
+	tampered := []byte("release bundle!")
+
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	digest := sha256.Sum256(data)
+	ecSig, _ := ecdsa.SignASN1(rand.Reader, ecPriv, digest[:])
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	// Test that raw and base64 ed25519 signatures verify
+	t.Run("Ed25519", func(t *testing.T) {
+		key := pemPublicKey(t, edPub)
+		sig := ed25519.Sign(edPriv, data)
+		for _, s := range [][]byte{sig, []byte(base64.StdEncoding.EncodeToString(sig) + "\n")} {
+			if ok, err := verifySignature(data, s, key, "ed25519"); err != nil || !ok {
+				t.Errorf("Expected valid signature, got %v, %v", ok, err)
+			}
+		}
+		if ok, _ := verifySignature(tampered, sig, key, "ed25519"); ok {
+			t.Error("Tampered data should not verify")
+		}
+		if ok, _ := verifySignature(data, []byte("short"), key, "ed25519"); ok {
+			t.Error("Malformed signature should not verify")
+		}
+	})
+
+	// Test that cosign-style base64 ECDSA P-256 signatures verify
+	t.Run("Cosign", func(t *testing.T) {
+		key := pemPublicKey(t, &ecPriv.PublicKey)
+		sig := []byte(base64.StdEncoding.EncodeToString(ecSig))
+		if ok, err := verifySignature(data, sig, key, "cosign"); err != nil || !ok {
+			t.Errorf("Expected valid signature, got %v, %v", ok, err)
+		}
+		if ok, _ := verifySignature(tampered, sig, key, "cosign"); ok {
+			t.Error("Tampered data should not verify")
+		}
+	})
+
+	// Test that both minisign algorithms verify, including the trusted comment
+	t.Run("Minisign", func(t *testing.T) {
+		for _, alg := range []string{"Ed", "ED"} {
+			pubFile, sigFile := minisignFiles(edPub, edPriv, keyID, alg, data)
+			if ok, err := verifySignature(data, []byte(sigFile), []byte(pubFile), "minisign"); err != nil || !ok {
+				t.Errorf("Expected valid %s signature, got %v, %v", alg, ok, err)
+			}
+			if ok, _ := verifySignature(tampered, []byte(sigFile), []byte(pubFile), "minisign"); ok {
+				t.Errorf("Tampered data should not verify with %s", alg)
+			}
+		}
+
+		pubFile, sigFile := minisignFiles(edPub, edPriv, keyID, "ED", data)
+		forged := []byte(sigFile[:len(sigFile)-1] + "x")
+		if ok, _ := verifySignature(data, forged, []byte(pubFile), "minisign"); ok {
+			t.Error("Modified global signature should not verify")
+		}
+
+		otherPub, _ := minisignFiles(edPub, edPriv, []byte{8, 7, 6, 5, 4, 3, 2, 1}, "ED", data)
+		if ok, _ := verifySignature(data, []byte(sigFile), []byte(otherPub), "minisign"); ok {
+			t.Error("Signature by a different key ID should not verify")
+		}
+	})
+
+	// Test that unusable keys and unknown schemes are errors
+	t.Run("RejectsBadKeysAndSchemes", func(t *testing.T) {
+		sig := ed25519.Sign(edPriv, data)
+		if _, err := verifySignature(data, sig, pemPublicKey(t, &ecPriv.PublicKey), "ed25519"); errorKind(err) != kindInvalidKey {
+			t.Errorf("Expected %s error, got %v", kindInvalidKey, err)
+		}
+		if _, err := verifySignature(data, sig, []byte("not a key"), "cosign"); errorKind(err) != kindInvalidKey {
+			t.Errorf("Expected %s error, got %v", kindInvalidKey, err)
+		}
+		if _, err := verifySignature(data, sig, pemPublicKey(t, edPub), "gpg"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+
+	// Test the function with keys from files and from signing_keys
+	t.Run("Function", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "plugin.zip")
+		sigfile := filepath.Join(dir, "plugin.zip.minisig")
+		keyfile := filepath.Join(dir, "release.pub")
+
+		pubFile, sigFile := minisignFiles(edPub, edPriv, keyID, "ED", data)
+		os.WriteFile(file, data, 0644)
+		os.WriteFile(sigfile, []byte(sigFile), 0644)
+		os.WriteFile(keyfile, []byte(pubFile), 0644)
+
+		root := &Root{}
+		root.Configure(map[string]interface{}{
+			"log_level": "off",
+			"signing_keys": map[string]interface{}{
+				"release": keyfile,
+				"inline":  minisignLines([]byte(pubFile))[1],
+			},
+		})
+		verify := root.Func("verify_signature").(func(string, string, string, string) (interface{}, error))
+
+		for _, key := range []string{keyfile, "release", "inline"} {
+			result, err := verify(file, sigfile, key, "minisign")
+			if err != nil || !*result.(*bool) {
+				t.Errorf("Expected %s to verify, got %v, %v", key, result, err)
+			}
+		}
+
+		result, err := verify(filepath.Join(dir, "missing.zip"), sigfile, "release", "minisign")
+		if result != nil || err != nil {
+			t.Errorf("Expected undefined for a missing file, got %v, %v", result, err)
+		}
+	})
+}
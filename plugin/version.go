@@ -0,0 +1,125 @@
+package plugin
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	goversion "github.com/hashicorp/go-version"
+)
+
+// Build information, set at build time with for example:
+//
+//	go build -ldflags "-X sentinel-plugin-demo/plugin.Version=1.4.0 \
+//	  -X sentinel-plugin-demo/plugin.GitCommit=$(git rev-parse HEAD)"
+//
+// GitCommit falls back to the VCS information the Go toolchain embeds
+// when building inside a git checkout.
+var (
+	Version   = "0.1.0"
+	GitCommit = ""
+	BuildDate = ""
+)
+
+const sdkModule = "github.com/hashicorp/sentinel-sdk"
+
+// Return structs
+type versionInfo struct {
+	Version       string
+	GitCommit     string
+	GitCommitDate string
+	GitDirty      bool
+	BuildDate     string
+	GoVersion     string
+	SDKVersion    string `sentinel:"sdk_version"`
+}
+
+// buildInfo collects the version of the running binary
+func buildInfo() *versionInfo {
+	info := &versionInfo{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, dep := range bi.Deps {
+		if dep.Path == sdkModule {
+			info.SDKVersion = dep.Version
+			if dep.Replace != nil {
+				info.SDKVersion = dep.Replace.Version
+			}
+		}
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.GitCommit == "" {
+				info.GitCommit = s.Value
+			}
+		case "vcs.time":
+			info.GitCommitDate = s.Value
+		case "vcs.modified":
+			info.GitDirty = s.Value == "true"
+		}
+	}
+
+	return info
+}
+
+// requireVersion checks the plugin version against a constraint such as
+// ">= 1.4" or "~> 1.4, != 1.4.2"
+func requireVersion(constraint string) error {
+	c, err := goversion.NewConstraint(constraint)
+	if err != nil {
+		return &kindError{Kind: kindInvalidArgument, Err: err}
+	}
+
+	v, err := goversion.NewVersion(Version)
+	if err != nil {
+		return fmt.Errorf("plugin was built with an invalid version %q: %s", Version, err)
+	}
+
+	if !c.Check(v) {
+		return &kindError{
+			Kind: kindVersionMismatch,
+			Err:  fmt.Errorf("plugin version %s does not satisfy %q", v, constraint),
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerFunc(&funcSpec{
+		Name:        "require_version",
+		Args:        []argSpec{{"constraint", "string"}},
+		Returns:     "bool",
+		Description: "Returns true if the plugin version satisfies a constraint such as \">= 1.4\", and fails with version_mismatch otherwise.",
+		Example:     `pd.require_version(">= 1.4")`,
+		New: func(r *Root) interface{} {
+			return func(constraint string) (interface{}, error) {
+				if err := requireVersion(constraint); err != nil {
+					return nil, err
+				}
+				return true, nil
+			}
+		},
+	})
+
+	registerProp(&propSpec{
+		Name:        "version",
+		Returns:     "map",
+		Description: "Version of the plugin binary: version, git_commit, git_commit_date, git_dirty, build_date, go_version and sdk_version.",
+		Example:     `pd.version.git_commit`,
+		Shape:       (*versionInfo)(nil),
+		Get: func(r *Root) (interface{}, error) {
+			return buildInfo(), nil
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	root := &Root{}
+
+	// Test that the version property reports the build
+	t.Run("PropertyReportsBuild", func(t *testing.T) {
+		result, err := root.Get("version")
+		if err != nil {
+			t.Fatalf("version property should not return error: %v", err)
+		}
+
+		info, ok := result.(*versionInfo)
+		if !ok {
+			t.Fatal("version should return a pointer to versionInfo")
+		}
+		if info.Version != Version {
+			t.Errorf("Expected version %s, got %s", Version, info.Version)
+		}
+		if info.GoVersion == "" {
+			t.Error("go_version should not be empty")
+		}
+	})
+
+	// Test that require_version passes and fails against the build version
+	t.Run("RequireVersion", func(t *testing.T) {
+		orig := Version
+		Version = "1.4.2"
+		defer func() { Version = orig }()
+
+		callable := root.Func("require_version").(func(string) (interface{}, error))
+		for _, c := range []string{">= 1.4", "~> 1.4.0", ">= 1.0, != 1.4.1"} {
+			result, err := callable(c)
+			if err != nil || result != true {
+				t.Errorf("%s: expected true, got %v, %v", c, result, err)
+			}
+		}
+
+		for _, c := range []string{">= 1.5", "< 1.4", "!= 1.4.2"} {
+			result, err := callable(c)
+			if result != nil || errorKind(err) != kindVersionMismatch {
+				t.Errorf("%s: expected %s error, got %v, %v", c, kindVersionMismatch, result, err)
+			}
+		}
+	})
+
+	// Test that invalid constraints are rejected
+	t.Run("RejectsInvalidConstraint", func(t *testing.T) {
+		callable := root.Func("require_version").(func(string) (interface{}, error))
+		if _, err := callable("newer please"); errorKind(err) != kindInvalidArgument {
+			t.Errorf("Expected %s error, got %v", kindInvalidArgument, err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	proto "github.com/hashicorp/sentinel-sdk/proto/go"
+)
+
+const replHelp = `Enter a property name or a function call with Sentinel literal arguments:
+
+  now
+  getenv("HOME")
+  pd.archive_read("bundle.tar.gz", "main.tf")
+
+Results are printed as Sentinel sees them after the plugin's encoding.
+
+Commands:
+  :list            List the functions and properties
+  :describe name   Describe a function or property
+  :help            Show this help
+  :quit            Exit (or Ctrl-D)
+`
+
+// runREPL reads expressions from in until EOF or :quit, printing each
+// result the way a policy would receive it.
+func runREPL(h *host, in io.Reader, out io.Writer) int {
+	fmt.Fprintln(out, "sentinel-plugin-demo REPL, type :help for help")
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return 0
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == ":quit" || line == ":q" || line == "exit":
+			return 0
+		case line == ":help":
+			fmt.Fprint(out, replHelp)
+			continue
+		case line == ":list":
+			cliDescribe(h, nil, out, out)
+			continue
+		case strings.HasPrefix(line, ":describe "):
+			cliDescribe(h, []string{strings.TrimSpace(strings.TrimPrefix(line, ":describe "))}, out, out)
+			continue
+		}
+
+		v, err := evalLine(h, line)
+		if err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+			continue
+		}
+		fmt.Fprintln(out, formatValue(v))
+	}
+}
+
+// evalLine evaluates a single "name" or "name(args...)" expression. A
+// leading import alias such as "pd." is ignored, so lines can be pasted
+// from policies.
+func evalLine(h *host, line string) (*proto.Value, error) {
+	name, rest, isCall := strings.Cut(line, "(")
+	name, err := stripAlias(h, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || strings.ContainsAny(name, " \t\"") {
+		return nil, fmt.Errorf("expected a property name or function call, got %q", line)
+	}
+
+	if !isCall {
+		return h.property(name)
+	}
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasSuffix(rest, ")") {
+		return nil, errors.New("missing closing parenthesis")
+	}
+	args, err := parseArgList(strings.TrimSuffix(rest, ")"))
+	if err != nil {
+		return nil, err
+	}
+	return h.call(name, args)
+}
+
+// stripAlias removes a leading import alias from name. The first segment
+// is kept when it names a function, property or namespace, so "git.head"
+// and "pd.git.head" both read the git namespace's head.
+func stripAlias(h *host, name string) (string, error) {
+	first, rest, ok := strings.Cut(name, ".")
+	if !ok {
+		return name, nil
+	}
+	entries, err := h.catalog()
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.Name == first || strings.HasPrefix(e.Name, first+".") {
+			return name, nil
+		}
+	}
+	return rest, nil
+}
+
+// parseArgList parses comma separated Sentinel literals. Sentinel's
+// string, number, bool, null, list and map literals are valid JSON, so the
+// list is decoded as the elements of a JSON array.
+func parseArgList(s string) ([]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader("[" + s + "]"))
+	dec.UseNumber()
+	var args []interface{}
+	if err := dec.Decode(&args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %s", err)
+	}
+	for i := range args {
+		args[i] = fromJSON(args[i])
+	}
+	return args, nil
+}
+
+// formatValue renders a value in Sentinel's literal syntax, with map keys
+// sorted so output is stable.
+func formatValue(v *proto.Value) string {
+	var b strings.Builder
+	writeValue(&b, v)
+	return b.String()
+}
+
+func writeValue(b *strings.Builder, v *proto.Value) {
+	switch v.Type {
+	case proto.Value_UNDEFINED:
+		b.WriteString("undefined")
+	case proto.Value_NULL:
+		b.WriteString("null")
+	case proto.Value_BOOL:
+		b.WriteString(strconv.FormatBool(v.GetValueBool()))
+	case proto.Value_INT:
+		b.WriteString(strconv.FormatInt(v.GetValueInt(), 10))
+	case proto.Value_FLOAT:
+		f := strconv.FormatFloat(v.GetValueFloat(), 'g', -1, 64)
+		if !strings.ContainsAny(f, ".eEn") {
+			f += ".0"
+		}
+		b.WriteString(f)
+	case proto.Value_STRING:
+		b.WriteString(strconv.Quote(v.GetValueString()))
+	case proto.Value_LIST:
+		b.WriteString("[")
+		for i, e := range v.GetValueList().GetElems() {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeValue(b, e)
+		}
+		b.WriteString("]")
+	case proto.Value_MAP:
+		elems := append([]*proto.Value_KV(nil), v.GetValueMap().GetElems()...)
+		sort.Slice(elems, func(i, j int) bool {
+			return formatValue(elems[i].Key) < formatValue(elems[j].Key)
+		})
+		b.WriteString("{")
+		for i, kv := range elems {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeValue(b, kv.Key)
+			b.WriteString(": ")
+			writeValue(b, kv.Value)
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(b, "<%s>", v.Type)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/sentinel-sdk/encoding"
+)
+
+func TestREPL(t *testing.T) {
+	h, err := newHost(map[string]interface{}{"log_level": "off"})
+	if err != nil {
+		t.Fatalf("newHost should not return error: %v", err)
+	}
+
+	// Test that expressions print their Sentinel values in order
+	t.Run("EvaluatesLines", func(t *testing.T) {
+		os.Setenv("TEST_REPL_VAR", "repl value")
+		defer os.Unsetenv("TEST_REPL_VAR")
+
+		in := strings.NewReader(strings.Join([]string{
+			`getenv("TEST_REPL_VAR")`,
+			`pd.encode("a b", "percent")`,
+			`getfile("/absolutely/non/existent/file/path/12345.txt")`,
+			`decode("zz", "hex")`,
+			`:quit`,
+			`getenv("NOT_REACHED")`,
+		}, "\n"))
+
+		var out bytes.Buffer
+		if code := runREPL(h, in, &out); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d", code)
+		}
+
+		want := []string{`"repl value"`, `"a%20b"`, `undefined`, `error: `}
+		got := strings.Split(out.String(), "> ")[1:]
+		if len(got) != len(want)+1 {
+			t.Fatalf("Expected %d prompts, got output %q", len(want)+1, out.String())
+		}
+		for i, w := range want {
+			if !strings.HasPrefix(got[i], w) {
+				t.Errorf("Line %d: expected %q, got %q", i, w, got[i])
+			}
+		}
+	})
+
+	// Test that namespaced names keep their namespace, with or without
+	// an import alias
+	t.Run("EvaluatesNamespaces", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+		dir := t.TempDir()
+		for _, args := range [][]string{
+			{"init", "-q", "-b", "main"},
+			{"-c", "user.name=Jane Doe", "-c", "user.email=jane@example.com", "commit", "-q", "--allow-empty", "-m", "First commit"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		orig, _ := os.Getwd()
+		os.Chdir(dir)
+		defer os.Chdir(orig)
+
+		for _, line := range []string{`git.branch`, `pd.git.branch`} {
+			v, err := evalLine(h, line)
+			if err != nil || formatValue(v) != `"main"` {
+				t.Errorf("%s: expected \"main\", got %v, %v", line, v, err)
+			}
+		}
+		for _, line := range []string{`git.commit("HEAD")`, `pd.git.commit("main")`, `pd.git.head`} {
+			v, err := evalLine(h, line)
+			if err != nil || !strings.Contains(formatValue(v), `"message": "First commit`) {
+				t.Errorf("%s: expected the first commit, got %v, %v", line, v, err)
+			}
+		}
+	})
+
+	// Test that malformed lines are reported
+	t.Run("RejectsMalformedLines", func(t *testing.T) {
+		for _, line := range []string{`getenv("HOME"`, `getenv(HOME)`, `"HOME"`} {
+			if _, err := evalLine(h, line); err == nil {
+				t.Errorf("evalLine(%q) should return error", line)
+			}
+		}
+	})
+}
+
+func TestFormatValue(t *testing.T) {
+	// Test that values use Sentinel literal syntax with sorted keys
+	t.Run("RendersLiterals", func(t *testing.T) {
+		v, err := encoding.GoToValue(map[string]interface{}{
+			"b": int64(1),
+			"a": []interface{}{"x", 2.0, nil, true},
+		})
+		if err != nil {
+			t.Fatalf("GoToValue should not return error: %v", err)
+		}
+
+		want := `{"a": ["x", 2.0, null, true], "b": 1}`
+		if got := formatValue(v); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	})
+
+	// Test that argument lists accept Sentinel literals
+	t.Run("ParsesArgumentLists", func(t *testing.T) {
+		args, err := parseArgList(`"a", 1, 2.5, true, null, ["x"], {"b": 1}`)
+		if err != nil {
+			t.Fatalf("parseArgList should not return error: %v", err)
+		}
+		if len(args) != 7 || args[1] != int64(1) || args[2] != 2.5 {
+			t.Errorf("Unexpected arguments %#v", args)
+		}
+	})
+}